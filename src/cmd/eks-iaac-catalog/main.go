@@ -0,0 +1,214 @@
+// eks-iaac-catalog regenerates the instance type catalog embedded in the utils package from the
+// output of the EC2 API, so that the nodegroups can be checked against it offline.
+//
+//	aws ec2 describe-instance-types --output json > instance-types.aws.json
+//	go run ./src/cmd/eks-iaac-catalog -in instance-types.aws.json -out src/utils/instance-types.json
+//
+// Without AWS credentials it reads the instances.json of ec2instances.info instead, which is collected
+// from the same API, with -format ec2instances.
+//
+// The instance types are grouped by family, the part of their name before the dot.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+)
+
+// describeInstanceTypesOutput is the subset of the aws ec2 describe-instance-types output the catalog is built from
+type describeInstanceTypesOutput struct {
+	InstanceTypes []instanceTypeInfo
+}
+
+// instanceTypeInfo is an instance type of the aws ec2 describe-instance-types output
+type instanceTypeInfo struct {
+	InstanceType      string
+	CurrentGeneration bool
+	ProcessorInfo     struct {
+		SupportedArchitectures []string
+	}
+	VCpuInfo struct {
+		DefaultVCpus int
+	}
+	MemoryInfo struct {
+		SizeInMiB int
+	}
+	GpuInfo *struct {
+		Gpus []device
+	}
+	InferenceAcceleratorInfo *struct {
+		Accelerators []device
+	}
+	NeuronInfo *struct{}
+}
+
+// ec2InstancesInfoInstance is the subset of an instance type of the ec2instances.info instances.json the catalog is built from
+type ec2InstancesInfoInstance struct {
+	InstanceType string   `json:"instance_type"`
+	Generation   string   `json:"generation"` // current or previous
+	Arch         []string `json:"arch"`
+	VCPU         int      `json:"vCPU"`
+	Memory       float64  `json:"memory"` // GiB
+	GPUModel     string   `json:"GPU_model"`
+}
+
+// device is a GPU or an inference accelerator of an instance type
+type device struct {
+	Manufacturer string
+}
+
+// eksArchitectures are the architectures of the EKS managed nodegroup AMIs
+var eksArchitectures = []string{"x86_64", "arm64"}
+
+func main() {
+	in := flag.String("in", "", "output of aws ec2 describe-instance-types in JSON")
+	format := flag.String("format", "aws", "format of the input: aws for describe-instance-types, ec2instances for the instances.json of ec2instances.info")
+	out := flag.String("out", "src/utils/instance-types.json", "catalog file the utils package embeds")
+	flag.Parse()
+	parse, ok := inputParsers[*format]
+	if *in == "" || !ok {
+		fmt.Fprintln(os.Stderr, "usage: eks-iaac-catalog -in <describe-instance-types.json> [-format aws|ec2instances] [-out <catalog.json>]")
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	output, err := parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *in, err)
+		os.Exit(1)
+	}
+	catalog, err := buildCatalog(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *in, err)
+		os.Exit(1)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	if err := writeCatalog(file, catalog); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// inputParsers read the instance types of the -format inputs
+var inputParsers = map[string]func(data []byte) (describeInstanceTypesOutput, error){
+	"aws":          parseDescribeInstanceTypes,
+	"ec2instances": parseEc2InstancesInfo,
+}
+
+func parseDescribeInstanceTypes(data []byte) (describeInstanceTypesOutput, error) {
+	var output describeInstanceTypesOutput
+	err := json.Unmarshal(data, &output)
+	return output, err
+}
+
+// parseEc2InstancesInfo converts the instances.json of ec2instances.info to the describe-instance-types output,
+// its accelerators are named by their model, and the newest Trainium families miss theirs
+func parseEc2InstancesInfo(data []byte) (describeInstanceTypesOutput, error) {
+	var instances []ec2InstancesInfoInstance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return describeInstanceTypesOutput{}, err
+	}
+
+	var output describeInstanceTypesOutput
+	for _, instance := range instances {
+		var instanceType instanceTypeInfo
+		instanceType.InstanceType = instance.InstanceType
+		instanceType.CurrentGeneration = instance.Generation == "current"
+		instanceType.ProcessorInfo.SupportedArchitectures = instance.Arch
+		instanceType.VCpuInfo.DefaultVCpus = instance.VCPU
+		instanceType.MemoryInfo.SizeInMiB = int(math.Round(instance.Memory * 1024))
+		switch {
+		case strings.HasPrefix(instance.GPUModel, "AWS Inferentia"), strings.HasPrefix(instance.GPUModel, "AWS Trainium"), strings.HasPrefix(instance.InstanceType, "trn"):
+			instanceType.NeuronInfo = &struct{}{}
+		case strings.HasPrefix(instance.GPUModel, "NVIDIA"):
+			instanceType.GpuInfo = &struct{ Gpus []device }{Gpus: []device{{Manufacturer: "NVIDIA"}}}
+		}
+		output.InstanceTypes = append(output.InstanceTypes, instanceType)
+	}
+	return output, nil
+}
+
+// buildCatalog groups the instance types of the describe-instance-types output by family
+func buildCatalog(output describeInstanceTypesOutput) (utils.InstanceCatalog, error) {
+	if len(output.InstanceTypes) == 0 {
+		return utils.InstanceCatalog{}, fmt.Errorf("no instance types found")
+	}
+
+	catalog := utils.InstanceCatalog{Families: map[string]utils.InstanceFamily{}}
+	for _, instanceType := range output.InstanceTypes {
+		familyName, size, ok := strings.Cut(instanceType.InstanceType, ".")
+		if !ok {
+			return utils.InstanceCatalog{}, fmt.Errorf("instance type %q is not like <family>.<size>", instanceType.InstanceType)
+		}
+
+		family, ok := catalog.Families[familyName]
+		if !ok {
+			family = utils.InstanceFamily{
+				Architecture:      getArchitecture(instanceType.ProcessorInfo.SupportedArchitectures),
+				CurrentGeneration: instanceType.CurrentGeneration,
+				Sizes:             map[string]utils.InstanceSize{},
+			}
+			family.SupportedInEks = slices.Contains(eksArchitectures, family.Architecture)
+		}
+		// a family is current when any of its sizes is, the accelerator is missing from the sizes like metal
+		family.CurrentGeneration = family.CurrentGeneration || instanceType.CurrentGeneration
+		switch {
+		case instanceType.NeuronInfo != nil:
+			family.Accelerator = utils.AcceleratorNeuron
+		case instanceType.InferenceAcceleratorInfo != nil && hasManufacturer(instanceType.InferenceAcceleratorInfo.Accelerators, "AWS"):
+			family.Accelerator = utils.AcceleratorNeuron
+		case instanceType.GpuInfo != nil && hasManufacturer(instanceType.GpuInfo.Gpus, "NVIDIA"):
+			family.Accelerator = utils.AcceleratorNvidia
+		}
+		family.Sizes[size] = utils.InstanceSize{Vcpus: instanceType.VCpuInfo.DefaultVCpus, MemoryMiB: instanceType.MemoryInfo.SizeInMiB}
+		catalog.Families[familyName] = family
+	}
+	return catalog, nil
+}
+
+// getArchitecture prefers the 64 bit architecture of the types also supporting i386
+func getArchitecture(architectures []string) string {
+	for _, architecture := range architectures {
+		if architecture != "i386" {
+			return architecture
+		}
+	}
+	if len(architectures) > 0 {
+		return architectures[0]
+	}
+	return ""
+}
+
+func hasManufacturer(devices []device, manufacturer string) bool {
+	for _, device := range devices {
+		if device.Manufacturer == manufacturer {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCatalog writes the catalog as indented JSON, the families and sizes sorted by name
+func writeCatalog(w io.Writer, catalog utils.InstanceCatalog) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(catalog)
+}
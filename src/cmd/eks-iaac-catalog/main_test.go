@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCatalog(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "describe-instance-types.json"))
+	require.NoError(t, err)
+	output, err := parseDescribeInstanceTypes(data)
+	require.NoError(t, err)
+	catalog, err := buildCatalog(output)
+	require.NoError(t, err)
+
+	require.Equal(t, utils.InstanceFamily{
+		Architecture:      "x86_64",
+		CurrentGeneration: true,
+		SupportedInEks:    true,
+		Sizes: map[string]utils.InstanceSize{
+			"large":  {Vcpus: 2, MemoryMiB: 8192},
+			"xlarge": {Vcpus: 4, MemoryMiB: 16384},
+		},
+	}, catalog.Families["m5"])
+	require.Equal(t, "x86_64", catalog.Families["t2"].Architecture)
+	require.False(t, catalog.Families["m4"].CurrentGeneration)
+	require.Equal(t, "arm64", catalog.Families["g5g"].Architecture)
+	require.Equal(t, utils.AcceleratorNvidia, catalog.Families["g5g"].Accelerator)
+	require.Equal(t, utils.AcceleratorNeuron, catalog.Families["inf1"].Accelerator)
+	require.Equal(t, utils.AcceleratorNeuron, catalog.Families["trn1"].Accelerator)
+	require.False(t, catalog.Families["mac2"].SupportedInEks)
+
+	// the written catalog is what the utils package embeds
+	var buffer bytes.Buffer
+	require.NoError(t, writeCatalog(&buffer, catalog))
+	var written utils.InstanceCatalog
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &written))
+	require.Equal(t, catalog, written)
+
+	_, err = buildCatalog(describeInstanceTypesOutput{})
+	require.EqualError(t, err, "no instance types found")
+	_, err = buildCatalog(describeInstanceTypesOutput{InstanceTypes: []instanceTypeInfo{{InstanceType: "m5"}}})
+	require.EqualError(t, err, `instance type "m5" is not like <family>.<size>`)
+}
+
+func TestBuildCatalogFromEc2InstancesInfo(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ec2instances.json"))
+	require.NoError(t, err)
+	output, err := parseEc2InstancesInfo(data)
+	require.NoError(t, err)
+	catalog, err := buildCatalog(output)
+	require.NoError(t, err)
+
+	require.Equal(t, utils.InstanceFamily{
+		Architecture:      "x86_64",
+		CurrentGeneration: true,
+		SupportedInEks:    true,
+		Sizes:             map[string]utils.InstanceSize{"large": {Vcpus: 2, MemoryMiB: 8192}},
+	}, catalog.Families["m5"])
+	require.False(t, catalog.Families["m3"].CurrentGeneration)
+	require.Equal(t, 3840, catalog.Families["m3"].Sizes["medium"].MemoryMiB)
+	require.Equal(t, 512, catalog.Families["t2"].Sizes["nano"].MemoryMiB)
+	require.Equal(t, "x86_64", catalog.Families["t2"].Architecture)
+	require.Equal(t, utils.AcceleratorNvidia, catalog.Families["g5g"].Accelerator)
+	require.Equal(t, utils.AcceleratorNeuron, catalog.Families["inf2"].Accelerator)
+	require.Equal(t, utils.AcceleratorNeuron, catalog.Families["trn2"].Accelerator)
+	require.Empty(t, catalog.Families["g4ad"].Accelerator)
+	require.False(t, catalog.Families["mac2"].SupportedInEks)
+
+	_, err = parseEc2InstancesInfo([]byte(`{"InstanceTypes": []}`))
+	require.Error(t, err)
+}
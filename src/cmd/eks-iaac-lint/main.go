@@ -0,0 +1,131 @@
+// eks-iaac-lint validates a clusters config tree with the same readers and validators as the
+// Pulumi program, without Pulumi credentials or a backend, so that it can run offline in CI.
+//
+//	go run ./src/cmd/eks-iaac-lint -format sarif clusters/dev > eks-iaac-lint.sarif
+//
+// With -render it prints the resolved YAML of every nodegroup, after merging the defaults and
+// rendering the templates, instead of the report.
+//
+// With -base it also checks the cluster versions against the config tree they are upgraded from, like a
+// checkout of the main branch, as EKS upgrades a cluster one minor version at a time.
+//
+// It exits with 1 when the config tree has errors, warnings alone do not fail it, and 2 when it is
+// invoked incorrectly.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], time.Now, os.Stdout, os.Stderr))
+}
+
+// run lints the config tree of args, the end of support of the cluster versions is compared to now
+func run(args []string, now func() time.Time, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eks-iaac-lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text, json or sarif")
+	render := flags.Bool("render", false, "print the resolved YAML of every nodegroup instead of the report")
+	verbose := flags.Bool("verbose", false, "print the logs of the readers")
+	base := flags.String("base", "", "config tree the clusters are upgraded from, to check the version upgrades")
+	versionWarningDays := flags.Int("version-warning-days", utils.DefaultVersionWarningDays, "days before the end of standard support a cluster version is warned about")
+	checkClusterDirNames := flags.Bool("check-cluster-dir-names", false, "require the name of every cluster to match its directory")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: eks-iaac-lint [-format text|json|sarif] [-render] [-verbose] [-base <clusters-config-path>] [-version-warning-days <days>] [-check-cluster-dir-names] <clusters-config-path>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	writeReport, ok := reportWriters[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown format %q, expected text, json or sarif\n", *format)
+		return 2
+	}
+
+	// the readers log every file they read, which is noise next to the report
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	rootDir := flags.Arg(0)
+	if _, err := os.Stat(rootDir); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	if *render {
+		return renderConfigTree(rootDir, stdout, stderr)
+	}
+
+	options := utils.TreeOptions{
+		CheckClusterDirNames: *checkClusterDirNames,
+		SupportPolicy:        utils.VersionPolicy{WarningDays: *versionWarningDays, Now: now},
+	}
+	tree, validationErrors := utils.ReadConfigTree(rootDir, options)
+	if *base != "" {
+		upgradeErrors, err := checkVersionUpgrades(tree.Clusters, *base)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		validationErrors = append(validationErrors, upgradeErrors...)
+	}
+	if err := writeReport(stdout, validationErrors); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	// the warnings are reported without failing the run
+	if errs, _ := validationErrors.Split(); len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkVersionUpgrades checks the versions of the valid clusters against those of the base config tree,
+// the errors of the invalid ones are already reported
+func checkVersionUpgrades(clusterConfigs map[string]utils.ClusterConfig, baseDir string) (utils.ValidationErrors, error) {
+	baseVersions, err := utils.ReadClusterVersions(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var validationErrors utils.ValidationErrors
+	errors.As(utils.CheckVersionUpgrades(clusterConfigs, baseVersions), &validationErrors)
+	return validationErrors, nil
+}
+
+// renderConfigTree prints the resolved nodegroups as a multi document YAML stream, and the errors
+// of the files that can not be resolved to stderr
+func renderConfigTree(rootDir string, stdout, stderr io.Writer) int {
+	rendered, err := utils.RenderConfigTree(rootDir)
+	for _, config := range rendered {
+		fmt.Fprintf(stdout, "---\n# %s\n%s", config.File, config.YAML)
+	}
+
+	var validationErrors utils.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		writeTextReport(stderr, validationErrors)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeInvalidTree writes a cluster whose config misses the version and has an invalid subnet
+func writeInvalidTree(t *testing.T) string {
+	rootDir := t.TempDir()
+	clusterDir := filepath.Join(rootDir, "my-cluster")
+	require.NoError(t, os.MkdirAll(filepath.Join(clusterDir, "nodegroups"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: my-cluster
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-123
+tags:
+  key: value
+`), 0644))
+	return rootDir
+}
+
+// writeWarningTree writes a valid cluster whose nodegroup uses a previous generation instance type
+func writeWarningTree(t *testing.T) string {
+	rootDir := t.TempDir()
+	clusterDir := filepath.Join(rootDir, "my-cluster")
+	require.NoError(t, os.MkdirAll(filepath.Join(clusterDir, "nodegroups"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: my-cluster
+version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "nodegroups", "old.yaml"), []byte(`name: old
+scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  ec2KeyPair: my-key
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  instanceTypes:
+    - m3.medium
+  diskSize: 20
+tags:
+  key: value
+kubernetesLabels:
+  team: payments
+`), 0644))
+	return rootDir
+}
+
+// fixedNow is the date the end of support of the cluster versions is checked on
+func fixedNow() time.Time {
+	return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRun(t *testing.T) {
+	t.Run("TestValidTree", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"../../components/testdata/clusters"}, fixedNow, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Equal(t, "no config validation errors found\n", stdout.String())
+	})
+
+	t.Run("TestTextReport", func(t *testing.T) {
+		rootDir := writeInvalidTree(t)
+		var stdout, stderr bytes.Buffer
+		code := run([]string{rootDir}, fixedNow, &stdout, &stderr)
+		require.Equal(t, 1, code)
+		configFile := filepath.Join(rootDir, "my-cluster", "config.yaml")
+		require.Contains(t, stdout.String(), configFile+":1:1: version: is required\n")
+		require.Contains(t, stdout.String(), configFile+`:8:5: subnetIds[0]: must be a subnet ID like subnet-0123456789abcdef0, got "subnet-123"`)
+		require.Contains(t, stdout.String(), "found 2 config validation error(s)\n")
+	})
+
+	t.Run("TestJSONReport", func(t *testing.T) {
+		rootDir := writeInvalidTree(t)
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-format", "json", rootDir}, fixedNow, &stdout, &stderr)
+		require.Equal(t, 1, code)
+
+		var report jsonReport
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		require.False(t, report.Valid)
+		require.Len(t, report.Errors, 2)
+		require.Equal(t, "subnetIds[0]", report.Errors[1].Path)
+		require.Equal(t, "subnetid", report.Errors[1].Rule)
+		require.Equal(t, 8, report.Errors[1].Line)
+	})
+
+	t.Run("TestSarifReport", func(t *testing.T) {
+		rootDir := writeInvalidTree(t)
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-format", "sarif", rootDir}, fixedNow, &stdout, &stderr)
+		require.Equal(t, 1, code)
+
+		var report sarifLog
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		require.Equal(t, "2.1.0", report.Version)
+		require.Len(t, report.Runs, 1)
+		require.Equal(t, []string{"required", "subnetid"}, []string{report.Runs[0].Tool.Driver.Rules[0].ID, report.Runs[0].Tool.Driver.Rules[1].ID})
+
+		result := report.Runs[0].Results[1]
+		require.Equal(t, "subnetid", result.RuleID)
+		require.Equal(t, "error", result.Level)
+		require.Equal(t, filepath.ToSlash(filepath.Join(rootDir, "my-cluster", "config.yaml")), result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		require.Equal(t, &sarifRegion{StartLine: 8, StartColumn: 5}, result.Locations[0].PhysicalLocation.Region)
+	})
+
+	t.Run("TestWarnings", func(t *testing.T) {
+		rootDir := writeWarningTree(t)
+		var stdout, stderr bytes.Buffer
+		code := run([]string{rootDir}, fixedNow, &stdout, &stderr)
+		require.Equal(t, 0, code, stdout.String())
+		require.Contains(t, stdout.String(), "computeConfiguration.instanceTypes[0]: warning: instance type m3.medium is of a previous generation")
+		require.Contains(t, stdout.String(), "found 0 config validation error(s) and 1 warning(s)\n")
+
+		stdout.Reset()
+		require.Equal(t, 0, run([]string{"-format", "json", rootDir}, fixedNow, &stdout, &stderr))
+		var report jsonReport
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		require.True(t, report.Valid)
+		require.Len(t, report.Errors, 1)
+		require.Equal(t, "warning", report.Errors[0].Severity)
+
+		stdout.Reset()
+		require.Equal(t, 0, run([]string{"-format", "sarif", rootDir}, fixedNow, &stdout, &stderr))
+		var sarif sarifLog
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &sarif))
+		require.Equal(t, "warning", sarif.Runs[0].Results[0].Level)
+		require.Equal(t, "instancetype_generation", sarif.Runs[0].Results[0].RuleID)
+	})
+
+	t.Run("TestBaseVersions", func(t *testing.T) {
+		rootDir := writeWarningTree(t)
+		baseDir := writeWarningTree(t)
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, run([]string{"-base", baseDir, rootDir}, fixedNow, &stdout, &stderr), stdout.String())
+
+		configFile := filepath.Join(baseDir, "my-cluster", "config.yaml")
+		config, err := os.ReadFile(configFile)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(configFile, bytes.Replace(config, []byte(`"1.29"`), []byte(`"1.27"`), 1), 0644))
+		stdout.Reset()
+		require.Equal(t, 1, run([]string{"-base", baseDir, rootDir}, fixedNow, &stdout, &stderr))
+		require.Contains(t, stdout.String(), filepath.Join(rootDir, "my-cluster", "config.yaml")+":2:1: version: version 1.29 is more than one minor version above the deployed version 1.27, upgrade to 1.28 first\n")
+
+		require.Equal(t, 2, run([]string{"-base", "does-not-exist", rootDir}, fixedNow, &stdout, &stderr))
+	})
+
+	t.Run("TestVersionWarningDays", func(t *testing.T) {
+		rootDir := writeWarningTree(t)
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, run([]string{"-version-warning-days", "400", rootDir}, fixedNow, &stdout, &stderr))
+		require.Contains(t, stdout.String(), "version: warning: version 1.29 reaches the end of standard support on 2025-03-23, in 295 day(s)\n")
+	})
+
+	t.Run("TestCheckClusterDirNames", func(t *testing.T) {
+		rootDir := writeWarningTree(t)
+		require.NoError(t, os.Rename(filepath.Join(rootDir, "my-cluster"), filepath.Join(rootDir, "renamed")))
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, run([]string{rootDir}, fixedNow, &stdout, &stderr))
+		stdout.Reset()
+		require.Equal(t, 1, run([]string{"-check-cluster-dir-names", rootDir}, fixedNow, &stdout, &stderr))
+		require.Contains(t, stdout.String(), `name: cluster name "my-cluster" must match its directory "renamed"`)
+	})
+
+	t.Run("TestRender", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-render", "../../components/testdata/clusters"}, fixedNow, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Contains(t, stdout.String(), "---\n# ../../components/testdata/clusters/test-cluster/nodegroups/on-demand.yaml\nname: on-demand\n")
+		require.Contains(t, stdout.String(), "---\n# ../../components/testdata/clusters/test-cluster/nodegroups/spot.yaml\nname: spot\n")
+	})
+
+	t.Run("TestUsageErrors", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 2, run([]string{}, fixedNow, &stdout, &stderr))
+		require.Equal(t, 2, run([]string{"-format", "xml", "."}, fixedNow, &stdout, &stderr))
+		require.Contains(t, stderr.String(), `unknown format "xml"`)
+		require.Equal(t, 2, run([]string{"does-not-exist"}, fixedNow, &stdout, &stderr))
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+)
+
+type reportWriter func(w io.Writer, validationErrors utils.ValidationErrors) error
+
+var reportWriters = map[string]reportWriter{
+	"text":  writeTextReport,
+	"json":  writeJSONReport,
+	"sarif": writeSarifReport,
+}
+
+// writeTextReport prints one "file:line:column: path: message" line per error, the format editors and CI logs link
+func writeTextReport(w io.Writer, validationErrors utils.ValidationErrors) error {
+	for _, validationError := range validationErrors {
+		if _, err := fmt.Fprintln(w, validationError.Error()); err != nil {
+			return err
+		}
+	}
+	errs, warnings := validationErrors.Split()
+	if len(errs) == 0 && len(warnings) == 0 {
+		_, err := fmt.Fprintln(w, "no config validation errors found")
+		return err
+	}
+	if len(warnings) == 0 {
+		_, err := fmt.Fprintf(w, "found %d config validation error(s)\n", len(errs))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "found %d config validation error(s) and %d warning(s)\n", len(errs), len(warnings))
+	return err
+}
+
+type jsonReport struct {
+	Valid  bool         `json:"valid"`
+	Errors []jsonResult `json:"errors"`
+}
+
+type jsonResult struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"` // "error" or "warning", only errors make the report invalid
+}
+
+func writeJSONReport(w io.Writer, validationErrors utils.ValidationErrors) error {
+	errs, _ := validationErrors.Split()
+	report := jsonReport{Valid: len(errs) == 0, Errors: []jsonResult{}}
+	for _, validationError := range validationErrors {
+		report.Errors = append(report.Errors, jsonResult{
+			File:     validationError.File,
+			Line:     validationError.Line,
+			Column:   validationError.Column,
+			Path:     validationError.Path,
+			Rule:     validationError.Rule,
+			Message:  validationError.Message,
+			Severity: getSeverity(validationError),
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// the subset of SARIF 2.1.0 read by code scanning to annotate the changed lines of a pull request
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func writeSarifReport(w io.Writer, validationErrors utils.ValidationErrors) error {
+	rules := map[string]bool{}
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "eks-iaac-lint", Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+	for _, validationError := range validationErrors {
+		ruleID := getSarifRuleID(validationError)
+		rules[ruleID] = true
+
+		message := validationError.Message
+		if validationError.Path != "" {
+			message = validationError.Path + ": " + message
+		}
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(validationError.File)},
+		}}
+		if validationError.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: validationError.Line, StartColumn: validationError.Column}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleID,
+			Level:     getSeverity(validationError),
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	ruleIDs := make([]string, 0, len(rules))
+	for ruleID := range rules {
+		ruleIDs = append(ruleIDs, ruleID)
+	}
+	sort.Strings(ruleIDs)
+	for _, ruleID := range ruleIDs {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               ruleID,
+			ShortDescription: sarifMessage{Text: fmt.Sprintf("config failed the %s validation", ruleID)},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// getSeverity returns the severity of the problem, named as the SARIF levels
+func getSeverity(validationError utils.ValidationError) string {
+	if validationError.Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+func getSarifRuleID(validationError utils.ValidationError) string {
+	if validationError.Rule == "" {
+		return "config"
+	}
+	return validationError.Rule
+}
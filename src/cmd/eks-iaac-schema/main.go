@@ -0,0 +1,52 @@
+// eks-iaac-schema writes the JSON schemas of the cluster, nodegroup and fargate profile YAML files,
+// which editors use for completion and validation of the config tree.
+//
+//	go run ./src/cmd/eks-iaac-schema -out schemas
+//
+// The schemas are committed, a test fails when they drift from the config structs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+)
+
+// schemaFile is a generated schema and the config it describes
+type schemaFile struct {
+	Name   string
+	Title  string
+	Config interface{}
+}
+
+var schemaFiles = []schemaFile{
+	{Name: "cluster.schema.json", Title: "EKS cluster config (clusters/<env>/<cluster>/config.yaml)", Config: utils.ClusterConfig{}},
+	{Name: "nodegroup.schema.json", Title: "EKS managed nodegroup config (clusters/<env>/<cluster>/nodegroups/*.yaml)", Config: utils.NodeGroupConfig{}},
+	{Name: "fargateprofile.schema.json", Title: "EKS fargate profile config (clusters/<env>/<cluster>/fargateprofiles/*.yaml)", Config: utils.FargateProfileConfig{}},
+}
+
+func main() {
+	outDir := flag.String("out", "schemas", "directory the schemas are written to")
+	flag.Parse()
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, file := range schemaFiles {
+		schema, err := utils.GenerateJSONSchema(file.Config, file.Title)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		path := filepath.Join(*outDir, file.Name)
+		if err := os.WriteFile(path, schema, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("Wrote", path)
+	}
+}
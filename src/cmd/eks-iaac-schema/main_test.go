@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// the committed schemas have to be regenerated with `make schema` whenever the config structs change
+func TestSchemasAreUpToDate(t *testing.T) {
+	for _, file := range schemaFiles {
+		t.Run(file.Name, func(t *testing.T) {
+			schema, err := utils.GenerateJSONSchema(file.Config, file.Title)
+			require.NoError(t, err)
+
+			committed, err := os.ReadFile(filepath.Join("..", "..", "..", "schemas", file.Name))
+			require.NoError(t, err)
+			require.Equal(t, string(committed), string(schema), "schemas/%s is out of date, run `make schema`", file.Name)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package components
+
+import (
+	"log"
+	"strings"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// getAccessEntryName returns the resource name of the access entry of a principal, the cluster name
+// followed by the account and path of the principal ARN, like "my-cluster-123456789012-role/sre"
+func getAccessEntryName(clusterName, principalArn string) string {
+	principal := principalArn[strings.LastIndex(principalArn, "::")+2:]
+	return clusterName + "-" + strings.Replace(principal, ":", "-", 1)
+}
+
+// getClusterAccessConfig returns the authentication mode of the cluster when the access section is present,
+// API_AND_CONFIG_MAP by default so that the principals mapped in the aws-auth ConfigMap keep their access
+func getClusterAccessConfig(clusterConfig utils.ClusterConfig) *eks.ClusterAccessConfigArgs {
+	if clusterConfig.Access == nil {
+		return nil
+	}
+
+	authenticationMode := clusterConfig.Access.AuthenticationMode
+	if authenticationMode == "" {
+		authenticationMode = "API_AND_CONFIG_MAP"
+	}
+	return &eks.ClusterAccessConfigArgs{
+		AuthenticationMode: pulumi.String(authenticationMode),
+	}
+}
+
+// createOrUpdateAccessEntries grants the principals of the access section access to the cluster, with an
+// access entry per principal and an access policy association per policy of the entry
+func createOrUpdateAccessEntries(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, cluster *eks.Cluster, opts ...pulumi.ResourceOption) ([]*eks.AccessEntry, error) {
+	if clusterConfig.Access == nil {
+		return nil, nil
+	}
+
+	var accessEntries []*eks.AccessEntry
+	for _, entryConfig := range clusterConfig.Access.Entries {
+		entryName := getAccessEntryName(clusterConfig.Name, entryConfig.PrincipalArn)
+		log.Printf("Creating access entry %s for principal: %s", entryName, entryConfig.PrincipalArn)
+
+		entryArgs := &eks.AccessEntryArgs{
+			ClusterName:      cluster.Name,
+			PrincipalArn:     pulumi.String(entryConfig.PrincipalArn),
+			KubernetesGroups: utils.ConvertToPulumiStringArray(entryConfig.KubernetesGroups),
+			Type:             pulumi.String("STANDARD"),
+			Tags:             utils.ConvertToPulumiStringMap(clusterConfig.Tags),
+		}
+		if entryConfig.Username != "" {
+			entryArgs.UserName = pulumi.String(entryConfig.Username)
+		}
+
+		entry, err := eks.NewAccessEntry(ctx, entryName, entryArgs, append(opts, pulumi.DependsOn([]pulumi.Resource{cluster}))...)
+		if err != nil {
+			log.Printf("Failed to create access entry: %s", entryName)
+			return nil, err
+		}
+		accessEntries = append(accessEntries, entry)
+
+		for _, policyConfig := range entryConfig.Policies {
+			accessScope := &eks.AccessPolicyAssociationAccessScopeArgs{
+				Type: pulumi.String(policyConfig.Scope),
+			}
+			if len(policyConfig.Namespaces) > 0 {
+				accessScope.Namespaces = utils.ConvertToPulumiStringArray(policyConfig.Namespaces)
+			}
+
+			// the principal is read from the entry, the association can only be made once the entry exists
+			_, err := eks.NewAccessPolicyAssociation(ctx, entryName+"-"+policyConfig.Name, &eks.AccessPolicyAssociationArgs{
+				ClusterName:  cluster.Name,
+				PrincipalArn: entry.PrincipalArn,
+				PolicyArn:    pulumi.String(utils.AccessPolicies[policyConfig.Name]),
+				AccessScope:  accessScope,
+			}, append(opts, pulumi.DependsOn([]pulumi.Resource{entry}))...)
+			if err != nil {
+				log.Printf("Failed to associate access policy %s with access entry: %s", policyConfig.Name, entryName)
+				return nil, err
+			}
+		}
+	}
+
+	log.Printf("Successfully created access entries for cluster: %s", clusterConfig.Name)
+
+	return accessEntries, nil
+}
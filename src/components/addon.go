@@ -0,0 +1,172 @@
+package components
+
+import (
+	"log"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// getAddonVersion returns the version of the add-on config, latest-compatible resolved to the most recent version
+// of the cluster's Kubernetes version, or an empty string to keep the installed version
+func getAddonVersion(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, addonConfig utils.AddonConfig) (string, error) {
+	if addonConfig.Version != utils.LatestCompatibleAddonVersion {
+		return addonConfig.Version, nil
+	}
+
+	result, err := eks.GetAddonVersion(ctx, &eks.GetAddonVersionArgs{
+		AddonName:         addonConfig.Name,
+		KubernetesVersion: clusterConfig.Version,
+		MostRecent:        pulumi.BoolRef(true),
+	})
+	if err != nil {
+		log.Printf("Failed to resolve the latest compatible version of addon %s for Kubernetes %s", addonConfig.Name, clusterConfig.Version)
+		return "", err
+	}
+	log.Printf("Resolved the latest compatible version of addon %s for Kubernetes %s: %s", addonConfig.Name, clusterConfig.Version, result.Version)
+	return result.Version, nil
+}
+
+func createOrUpdateAddon(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, addonConfig utils.AddonConfig, cluster *EksCluster, dependsOn []pulumi.Resource, opts ...pulumi.ResourceOption) (*eks.Addon, error) {
+	log.Printf("Creating or updating addon %s for cluster: %s", addonConfig.Name, clusterConfig.Name)
+
+	addonArgs := &eks.AddonArgs{
+		ClusterName: cluster.Cluster.Name,
+		AddonName:   pulumi.String(addonConfig.Name),
+		Tags:        utils.ConvertToPulumiStringMap(clusterConfig.Tags),
+	}
+	addonVersion, err := getAddonVersion(ctx, clusterConfig, addonConfig)
+	if err != nil {
+		return nil, err
+	}
+	if addonVersion != "" {
+		addonArgs.AddonVersion = pulumi.String(addonVersion)
+	}
+	configurationValues, err := utils.ConvertConfigurationValuesToJSON(addonConfig.ConfigurationValues)
+	if err != nil {
+		return nil, err
+	}
+	if configurationValues != "" {
+		addonArgs.ConfigurationValues = pulumi.String(configurationValues)
+	}
+	if addonConfig.ResolveConflicts != "" {
+		addonArgs.ResolveConflicts = pulumi.String(addonConfig.ResolveConflicts)
+	}
+
+	if addonConfig.ServiceAccountRoleArn != "" {
+		addonArgs.ServiceAccountRoleArn = pulumi.String(addonConfig.ServiceAccountRoleArn)
+	} else if addonConfig.CreateServiceAccountRole {
+		serviceAccountRole := *utils.KnownAddons[addonConfig.Name].ServiceAccount
+		roleName := utils.GetAddonRoleName(clusterConfig.Name, addonConfig.Name)
+		log.Printf("Creating IRSA role %s for addon: %s", roleName, addonConfig.Name)
+
+		role, err := createServiceAccountRole(ctx, roleName, serviceAccountRole, cluster.OidcProvider, cluster.OidcIssuer, clusterConfig.Oidc.GetClientIds(), clusterConfig.Tags, opts...)
+		if err != nil {
+			log.Printf("Failed to create IRSA role: %s", roleName)
+			return nil, err
+		}
+		addonArgs.ServiceAccountRoleArn = role.Arn
+		dependsOn = append(dependsOn, role)
+	}
+
+	opts = append(opts, pulumi.DependsOn(dependsOn))
+	addon, err := eks.NewAddon(ctx, clusterConfig.Name+"-"+addonConfig.Name, addonArgs, opts...)
+	if err != nil {
+		log.Printf("Failed to create or update addon %s for cluster: %s", addonConfig.Name, clusterConfig.Name)
+		return nil, err
+	}
+
+	log.Printf("Successfully created or updated addon %s for cluster: %s", addonConfig.Name, clusterConfig.Name)
+
+	return addon, nil
+}
+
+// CreateOrUpdateNodeGroupsAndAddons creates the nodegroups and add-ons of the cluster in the order a version
+// upgrade has to go through in a single update: the control plane first, then the add-ons, then the nodegroups
+// one at a time in the order of their configs. Add-ons that run deployments need a schedulable node, on a new
+// cluster they wait for the nodegroups up to the first one without taints and the nodegroups after it wait for
+// them, on a cluster with deployed nodegroups they run on the existing nodes and are upgraded before the
+// nodegroups. The nodegroups in unchanged are left as they are deployed, the tracker records the resources of each.
+func CreateOrUpdateNodeGroupsAndAddons(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, nodeGroupConfigs []utils.NodeGroupConfig, cluster *EksCluster, unchanged map[string]bool, hasNodes bool, tracker *ResourceTracker) ([]*ManagedNodeGroup, []*eks.Addon, error) {
+	var addons []*eks.Addon
+	var addonResources []pulumi.Resource
+
+	createAddons := func(requiresNodes bool, dependsOn []pulumi.Resource) error {
+		for _, addonConfig := range clusterConfig.Addons {
+			if utils.KnownAddons[addonConfig.Name].RequiresNodes != requiresNodes {
+				continue
+			}
+			addon, err := createOrUpdateAddon(ctx, clusterConfig, addonConfig, cluster, append([]pulumi.Resource{cluster.Cluster}, dependsOn...), pulumi.Parent(cluster))
+			if err != nil {
+				return err
+			}
+			addons = append(addons, addon)
+			addonResources = append(addonResources, addon)
+		}
+		return nil
+	}
+
+	if err := createAddons(false, nil); err != nil {
+		return nil, nil, err
+	}
+
+	// the add-ons needing nodes wait for every nodegroup when all of them are tainted, unless the cluster has nodes
+	schedulable := len(nodeGroupConfigs) - 1
+	for i, nodeGroupConfig := range nodeGroupConfigs {
+		if len(nodeGroupConfig.KubernetesTaints) == 0 {
+			schedulable = i
+			break
+		}
+	}
+	if hasNodes || len(nodeGroupConfigs) == 0 {
+		if !hasNodes && hasAddonRequiringNodes(clusterConfig) {
+			log.Printf("Cluster %s has add-ons that need nodes but no nodegroups, they will stay degraded until nodes join", clusterConfig.Name)
+		}
+		if err := createAddons(true, nil); err != nil {
+			return nil, nil, err
+		}
+		schedulable = -1
+	}
+
+	var nodeGroups []*ManagedNodeGroup
+	var nodeGroupResources []pulumi.Resource
+	for i, nodeGroupConfig := range nodeGroupConfigs {
+		opts := []pulumi.ResourceOption{
+			pulumi.Parent(cluster),
+			tracker.Track(clusterConfig.Name+"/"+nodeGroupConfig.Name, unchanged[nodeGroupConfig.Name]),
+		}
+
+		// every nodegroup waits for the add-ons created so far and the nodegroup before it
+		dependsOn := append([]pulumi.Resource{}, addonResources...)
+		if len(nodeGroups) > 0 {
+			dependsOn = append(dependsOn, nodeGroups[len(nodeGroups)-1].NodeGroup)
+		}
+
+		// the component name carries the cluster name, nodegroup names are only unique per cluster
+		nodeGroup, err := NewManagedNodeGroup(ctx, clusterConfig.Name+"-"+nodeGroupConfig.Name, nodeGroupConfig, cluster, clusterConfig.Name, dependsOn, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		nodeGroups = append(nodeGroups, nodeGroup)
+		nodeGroupResources = append(nodeGroupResources, nodeGroup.NodeGroup)
+
+		if i == schedulable {
+			if err := createAddons(true, nodeGroupResources); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return nodeGroups, addons, nil
+}
+
+// hasAddonRequiringNodes returns whether the cluster has an add-on that runs deployments
+func hasAddonRequiringNodes(clusterConfig utils.ClusterConfig) bool {
+	for _, addonConfig := range clusterConfig.Addons {
+		if utils.KnownAddons[addonConfig.Name].RequiresNodes {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package components
+
+import "strings"
+
+// getRegionFromArn extracts the region from an ARN like "arn:aws:eks:ap-south-1:123456789012:cluster/name"
+func getRegionFromArn(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[3]
+}
+
+// getAccountIdFromArn extracts the account ID from an ARN like "arn:aws:iam::123456789012:role/name"
+func getAccountIdFromArn(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
@@ -4,29 +4,202 @@ import (
 	"log"
 
 	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
-	"github.com/pulumi/pulumi-aws/sdk/v4/go/aws/eks"
-	"github.com/pulumi/pulumi-aws/sdk/v4/go/aws/iam" // Add this import statement
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-func createOrUpdateCluster(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, clusterRole *iam.Role) (*eks.Cluster, error) {
+// EksCluster is a component resource that groups the cluster role, its policy
+// attachments and the EKS cluster itself under a single node in the stack.
+type EksCluster struct {
+	pulumi.ResourceState
+
+	Cluster      *eks.Cluster
+	Role         *iam.Role
+	OidcProvider *iam.OpenIdConnectProvider
+
+	Name                     pulumi.StringOutput    `pulumi:"name"`
+	Version                  pulumi.StringOutput    `pulumi:"version"`
+	Arn                      pulumi.StringOutput    `pulumi:"arn"`
+	Endpoint                 pulumi.StringOutput    `pulumi:"endpoint"`
+	CertificateAuthorityData pulumi.StringOutput    `pulumi:"certificateAuthorityData"`
+	OidcIssuer               pulumi.StringOutput    `pulumi:"oidcIssuer"`
+	RoleArn                  pulumi.StringOutput    `pulumi:"roleArn"`
+	SecurityGroupId          pulumi.StringOutput    `pulumi:"securityGroupId"`
+	Kubeconfig               pulumi.StringOutput    `pulumi:"kubeconfig"`
+	OidcProviderArn          pulumi.StringOutput    `pulumi:"oidcProviderArn"`
+	ServiceAccountRoleArns   pulumi.StringMapOutput `pulumi:"serviceAccountRoleArns"`
+}
+
+// NewEksCluster registers the EksCluster component and all of its children.
+func NewEksCluster(ctx *pulumi.Context, name string, clusterConfig utils.ClusterConfig, opts ...pulumi.ResourceOption) (*EksCluster, error) {
+	component := &EksCluster{}
+	err := ctx.RegisterComponentResource("eks-iaac:components:EksCluster", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// children used to live at the stack root, the alias keeps their URNs stable
+	childOpts := []pulumi.ResourceOption{
+		pulumi.Parent(component),
+		pulumi.Aliases([]pulumi.Alias{{NoParent: pulumi.Bool(true)}}),
+	}
+
+	// check if roleArn is empty, if so, create a new role with suffix "-eks-cluster-role"
+	clusterRole, err := getOrCreateClusterRole(ctx, clusterConfig, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// secrets are envelope encrypted with a KMS key when the encryption section is present
+	var encryptionKeyArn pulumi.StringInput
+	if clusterConfig.Encryption != nil {
+		encryptionKeyArn, err = getOrCreateClusterEncryptionKeyArn(ctx, clusterConfig, clusterRole, pulumi.Parent(component))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the log group is created up front when it has a retention or KMS key, so EKS does not create one without them
+	clusterOpts := childOpts
+	if clusterConfig.HasClusterLogGroup() {
+		logGroup, err := createClusterLogGroup(ctx, clusterConfig, pulumi.Parent(component))
+		if err != nil {
+			return nil, err
+		}
+		clusterOpts = append(clusterOpts, pulumi.DependsOn([]pulumi.Resource{logGroup}))
+	}
+
+	cluster, err := createOrUpdateCluster(ctx, clusterConfig, clusterRole, encryptionKeyArn, clusterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	component.Cluster = cluster
+	component.Role = clusterRole
+	component.Name = cluster.Name
+	component.Version = cluster.Version
+	component.Arn = cluster.Arn
+	component.Endpoint = cluster.Endpoint
+	component.CertificateAuthorityData = cluster.CertificateAuthority.Data().Elem()
+	component.OidcIssuer = cluster.Identities.ApplyT(getOidcIssuer).(pulumi.StringOutput)
+	component.RoleArn = clusterRole.Arn
+	component.SecurityGroupId = cluster.VpcConfig.ClusterSecurityGroupId().Elem()
+	component.Kubeconfig = pulumi.ToSecret(generateKubeconfig(cluster.Name, cluster.Arn, component.Endpoint, component.CertificateAuthorityData)).(pulumi.StringOutput)
+
+	err = createOidcResources(ctx, component, clusterConfig, pulumi.Parent(component))
+	if err != nil {
+		return nil, err
+	}
+
+	// the IAM principals of the access section are granted access through access entries
+	_, err = createOrUpdateAccessEntries(ctx, clusterConfig, cluster, pulumi.Parent(component))
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"name":                     component.Name,
+		"version":                  component.Version,
+		"arn":                      component.Arn,
+		"endpoint":                 component.Endpoint,
+		"certificateAuthorityData": component.CertificateAuthorityData,
+		"oidcIssuer":               component.OidcIssuer,
+		"roleArn":                  component.RoleArn,
+		"securityGroupId":          component.SecurityGroupId,
+		"kubeconfig":               component.Kubeconfig,
+		"oidcProviderArn":          component.OidcProviderArn,
+		"serviceAccountRoleArns":   component.ServiceAccountRoleArns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return component, nil
+}
+
+// getOidcIssuer returns the OIDC issuer URL of the cluster, or an empty string
+// while the cluster has not reported any identities yet
+func getOidcIssuer(identities []eks.ClusterIdentity) string {
+	for _, identity := range identities {
+		for _, oidc := range identity.Oidcs {
+			if oidc.Issuer != nil {
+				return *oidc.Issuer
+			}
+		}
+	}
+	return ""
+}
+
+// createOidcResources creates the IAM OIDC provider and the IRSA roles of the cluster
+// when the oidc section is present in the cluster config
+func createOidcResources(ctx *pulumi.Context, component *EksCluster, clusterConfig utils.ClusterConfig, opts ...pulumi.ResourceOption) error {
+	component.OidcProviderArn = pulumi.String("").ToStringOutput()
+	component.ServiceAccountRoleArns = pulumi.StringMap{}.ToStringMapOutput()
+	if clusterConfig.Oidc == nil {
+		return nil
+	}
+
+	opts = append(opts, pulumi.DependsOn([]pulumi.Resource{component.Cluster}))
+	provider, err := createOidcProvider(ctx, clusterConfig, component.OidcIssuer, opts...)
+	if err != nil {
+		return err
+	}
+	component.OidcProvider = provider
+	component.OidcProviderArn = provider.Arn
+
+	// the output reads the map, so it is only taken once the map is complete
+	serviceAccountRoleArns := pulumi.StringMap{}
+	for _, serviceAccountRole := range clusterConfig.ServiceAccountRoles {
+		roleName := serviceAccountRole.GetRoleName(clusterConfig.Name)
+		log.Printf("Creating IRSA role %s for service account %s/%s", roleName, serviceAccountRole.Namespace, serviceAccountRole.ServiceAccountName)
+
+		role, err := createServiceAccountRole(ctx, roleName, serviceAccountRole, provider, component.OidcIssuer, clusterConfig.Oidc.GetClientIds(), clusterConfig.Tags, opts...)
+		if err != nil {
+			log.Printf("Failed to create IRSA role: %s", roleName)
+			return err
+		}
+		serviceAccountRoleArns[serviceAccountRole.Namespace+"/"+serviceAccountRole.ServiceAccountName] = role.Arn
+	}
+	component.ServiceAccountRoleArns = serviceAccountRoleArns.ToStringMapOutput()
+
+	return nil
+}
+
+func createOrUpdateCluster(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, clusterRole *iam.Role, encryptionKeyArn pulumi.StringInput, opts ...pulumi.ResourceOption) (*eks.Cluster, error) {
 	log.Printf("Creating EKS cluster: %s", clusterConfig.Name)
 
- 	cluster, err := eks.NewCluster(ctx, clusterConfig.Name, &eks.ClusterArgs{
+	clusterArgs := &eks.ClusterArgs{
 		Name:    pulumi.String(clusterConfig.Name),
 		RoleArn: clusterRole.Arn,
 		KubernetesNetworkConfig: &eks.ClusterKubernetesNetworkConfigArgs{
 			ServiceIpv4Cidr: pulumi.String(clusterConfig.ServiceIpv4Cidr),
 		},
 		VpcConfig: &eks.ClusterVpcConfigArgs{
-			PublicAccessCidrs: utils.ConvertToPulumiStringArray(clusterConfig.PublicAccessCidrs), // Convert []string to pulumi.StringArray
-			SecurityGroupIds:  utils.ConvertToPulumiStringArray(clusterConfig.SecurityGroupIds),
-			SubnetIds:         utils.ConvertToPulumiStringArray(clusterConfig.SubnetIds),
+			PublicAccessCidrs:     utils.ConvertToPulumiStringArray(clusterConfig.PublicAccessCidrs), // Convert []string to pulumi.StringArray
+			SecurityGroupIds:      utils.ConvertToPulumiStringArray(clusterConfig.SecurityGroupIds),
+			SubnetIds:             utils.ConvertToPulumiStringArray(clusterConfig.SubnetIds),
+			EndpointPrivateAccess: pulumi.Bool(clusterConfig.IsEndpointPrivateAccess()),
+			EndpointPublicAccess:  pulumi.Bool(clusterConfig.IsEndpointPublicAccess()),
 		},
-		Version: pulumi.String(clusterConfig.Version),
-		Tags: utils.ConvertToPulumiStringMap(clusterConfig.Tags), // Convert map[string]string to pulumi.StringMap
-        EnabledClusterLogTypes: pulumi.StringArray{pulumi.String("api"), pulumi.String("audit"), pulumi.String("authenticator"), pulumi.String("controllerManager"), pulumi.String("scheduler")},
-	}, pulumi.DependsOn([]pulumi.Resource{clusterRole}))
+		Version:                pulumi.String(clusterConfig.Version),
+		Tags:                   utils.ConvertToPulumiStringMap(clusterConfig.Tags), // Convert map[string]string to pulumi.StringMap
+		EnabledClusterLogTypes: utils.ConvertToPulumiStringArray(clusterConfig.GetEnabledLogTypes()),
+	}
+	if accessConfig := getClusterAccessConfig(clusterConfig); accessConfig != nil {
+		clusterArgs.AccessConfig = accessConfig
+	}
+	if encryptionKeyArn != nil {
+		clusterArgs.EncryptionConfig = &eks.ClusterEncryptionConfigArgs{
+			Provider: &eks.ClusterEncryptionConfigProviderArgs{
+				KeyArn: encryptionKeyArn,
+			},
+			Resources: pulumi.StringArray{pulumi.String("secrets")},
+		}
+	}
+
+	opts = append(opts, pulumi.DependsOn([]pulumi.Resource{clusterRole}))
+	cluster, err := eks.NewCluster(ctx, clusterConfig.Name, clusterArgs, opts...)
 
 	if err != nil {
 		log.Printf("Failed to create EKS cluster: %s", clusterConfig.Name)
@@ -38,27 +211,38 @@ func createOrUpdateCluster(ctx *pulumi.Context, clusterConfig utils.ClusterConfi
 	return cluster, nil
 }
 
-func CreateOrUpdateClusters(ctx *pulumi.Context, clusterConfigs []utils.ClusterConfig) ([]*eks.Cluster, error){
-	var clusters []*eks.Cluster
+// CreateOrUpdateClusters creates the clusters, the clusters in unchanged are left as they are deployed
+// along with their nodegroups, fargate profiles and addons, the tracker records the resources of each
+func CreateOrUpdateClusters(ctx *pulumi.Context, clusterConfigs map[string]utils.ClusterConfig, unchanged map[string]bool, tracker *ResourceTracker) (map[string]*EksCluster, error) {
+	clusters := map[string]*EksCluster{}
 
-	// Iterate over the clusterConfigs and create each cluster
-	for _, clusterConfig := range clusterConfigs {
-		log.Printf("Creating cluster: %s", clusterConfig.Name)
+	// Iterate over the clusterConfigs by name and create each cluster
+	for _, name := range utils.GetClusterNames(clusterConfigs) {
+		log.Printf("Creating cluster: %s", name)
 
-		// check if roleArn is empty, if so, create a new role with suffix "-eks-cluster-role"
-		clusterRole, err := getOrCreateClusterRole(ctx, clusterConfig)
-		if err != nil {
-			return nil, err
-		}
-		
-		// Use the CreateCluster function from src/components/cluster.go to create the cluster
-		cluster, err := createOrUpdateCluster(ctx, clusterConfig, clusterRole)
+		cluster, err := NewEksCluster(ctx, name, clusterConfigs[name], tracker.Track(name, unchanged[name]))
 		if err != nil {
 			return nil, err
 		}
 
-		clusters = append(clusters, cluster)
+		clusters[name] = cluster
 	}
 
 	return clusters, nil
 }
+
+// ExportClusterOutputs exports the connection details of the cluster as a stack output
+// keyed by the cluster name, the kubeconfig is exported as a secret
+func ExportClusterOutputs(ctx *pulumi.Context, clusterName string, cluster *EksCluster) {
+	ctx.Export(clusterName, pulumi.Map{
+		"endpoint":                 cluster.Endpoint,
+		"version":                  cluster.Version,
+		"certificateAuthorityData": cluster.CertificateAuthorityData,
+		"arn":                      cluster.Arn,
+		"securityGroupId":          cluster.SecurityGroupId,
+		"oidcIssuerUrl":            cluster.OidcIssuer,
+		"kubeconfig":               cluster.Kubeconfig,
+		"oidcProviderArn":          cluster.OidcProviderArn,
+		"serviceAccountRoleArns":   cluster.ServiceAccountRoleArns,
+	})
+}
@@ -0,0 +1,671 @@
+package components_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/components"
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/require"
+)
+
+const testClusterDir = "testdata/clusters/test-cluster"
+
+// registeredResource is what the mocks saw for a single resource registration
+type registeredResource struct {
+	Type          string
+	Name          string
+	ID            string
+	Custom        bool
+	Parent        string
+	Inputs        resource.PropertyMap
+	Dependencies  []string
+	Aliases       int
+	IgnoreChanges []string
+}
+
+// mocks records every resource registered by the program and fills in the
+// outputs AWS would compute so that the components can wire them together
+type mocks struct {
+	sync.Mutex
+	resources []registeredResource
+
+	// stackOutputs are the outputs of the last update read through a reference to the stack
+	stackOutputs resource.PropertyMap
+
+	// clusterNames and nodeGroupNames, by cluster, are the clusters and nodegroups that exist in AWS,
+	// clusterVersions the versions of the clusters
+	clusterNames    []string
+	nodeGroupNames  map[string][]string
+	clusterVersions map[string]string
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	registered := registeredResource{
+		Type:   args.TypeToken,
+		Name:   args.Name,
+		ID:     args.ID,
+		Custom: args.Custom,
+		Inputs: args.Inputs,
+	}
+	if args.RegisterRPC != nil {
+		registered.Parent = getNameFromUrn(args.RegisterRPC.GetParent())
+		for _, dependency := range args.RegisterRPC.GetDependencies() {
+			registered.Dependencies = append(registered.Dependencies, getNameFromUrn(dependency))
+		}
+		registered.Aliases = len(args.RegisterRPC.GetAliases())
+		registered.IgnoreChanges = args.RegisterRPC.GetIgnoreChanges()
+	}
+	// existing resources are read, the mocks report them as not custom
+	if args.ReadRPC != nil {
+		registered.Custom = true
+		registered.Parent = getNameFromUrn(args.ReadRPC.GetParent())
+	}
+
+	m.Lock()
+	m.resources = append(m.resources, registered)
+	m.Unlock()
+
+	outputs := args.Inputs.Copy()
+	arn := "arn:aws:" + strings.Split(args.TypeToken, ":")[1] + ":ap-south-1:123456789012:" + args.Name
+	switch args.TypeToken {
+	case "aws:eks/cluster:Cluster":
+		arn = "arn:aws:eks:ap-south-1:123456789012:cluster/" + args.Name
+		outputs["endpoint"] = resource.NewStringProperty("https://EXAMPLE.gr7.ap-south-1.eks.amazonaws.com")
+		outputs["certificateAuthority"] = resource.NewObjectProperty(resource.PropertyMap{
+			"data": resource.NewStringProperty("Y2VydGlmaWNhdGU="),
+		})
+		vpcConfig := args.Inputs["vpcConfig"].ObjectValue().Copy()
+		vpcConfig["clusterSecurityGroupId"] = resource.NewStringProperty("sg-0c1a55e7c1a55e7c1")
+		outputs["vpcConfig"] = resource.NewObjectProperty(vpcConfig)
+		outputs["identities"] = resource.NewArrayProperty([]resource.PropertyValue{
+			resource.NewObjectProperty(resource.PropertyMap{
+				"oidcs": resource.NewArrayProperty([]resource.PropertyValue{
+					resource.NewObjectProperty(resource.PropertyMap{
+						"issuer": resource.NewStringProperty("https://oidc.eks.ap-south-1.amazonaws.com/id/EXAMPLE"),
+					}),
+				}),
+			}),
+		})
+	case "aws:iam/role:Role":
+		arn = "arn:aws:iam::123456789012:role/" + args.Name
+	case "aws:ec2/launchTemplate:LaunchTemplate":
+		outputs["latestVersion"] = resource.NewNumberProperty(1)
+	case "pulumi:pulumi:StackReference":
+		outputs["outputs"] = resource.NewObjectProperty(m.stackOutputs)
+		outputs["secretOutputNames"] = resource.NewArrayProperty(nil)
+	}
+	// existing resources are read by their ARN
+	if strings.HasPrefix(args.ID, "arn:") {
+		arn = args.ID
+	}
+	outputs["arn"] = resource.NewStringProperty(arn)
+
+	return args.Name + "_id", outputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	switch args.Token {
+	case "aws:eks/getClusters:getClusters":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"id": "ap-south-1", "names": m.clusterNames}), nil
+	case "aws:eks/getNodeGroups:getNodeGroups":
+		clusterName := args.Args["clusterName"].StringValue()
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"id": clusterName, "clusterName": clusterName, "names": m.nodeGroupNames[clusterName]}), nil
+	case "aws:eks/getCluster:getCluster":
+		result := args.Args.Copy()
+		result["id"] = args.Args["name"]
+		result["version"] = resource.NewStringProperty(m.clusterVersions[args.Args["name"].StringValue()])
+		return result, nil
+	case "aws:eks/getAddonVersion:getAddonVersion":
+		result := args.Args.Copy()
+		result["version"] = resource.NewStringProperty("v1.18.3-eksbuild.2")
+		return result, nil
+	}
+	return args.Args, nil
+}
+
+// get returns the resource registered with the given type and name
+func (m *mocks) get(t *testing.T, typeToken, name string) registeredResource {
+	t.Helper()
+	for _, registered := range m.resources {
+		if registered.Type == typeToken && registered.Name == name {
+			return registered
+		}
+	}
+	require.Failf(t, "resource not registered", "%s %s", typeToken, name)
+	return registeredResource{}
+}
+
+// count returns the number of resources registered with the given type
+func (m *mocks) count(typeToken string) int {
+	count := 0
+	for _, registered := range m.resources {
+		if registered.Type == typeToken {
+			count++
+		}
+	}
+	return count
+}
+
+// getResourceKeys returns "<type>::<name>" of the component and every resource under it, as the program exports them
+func (m *mocks) getResourceKeys(componentName string) []string {
+	parents := map[string]string{}
+	for _, registered := range m.resources {
+		if !registered.Custom {
+			parents[registered.Name] = registered.Parent
+		}
+	}
+
+	var keys []string
+	for _, registered := range m.resources {
+		name := registered.Name
+		if registered.Custom {
+			name = registered.Parent
+		}
+		for ; name != ""; name = parents[name] {
+			if name == componentName {
+				keys = append(keys, registered.Type+"::"+registered.Name)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// toPropertyArray converts the strings into an array property
+func toPropertyArray(values []string) resource.PropertyValue {
+	var items []resource.PropertyValue
+	for _, value := range values {
+		items = append(items, resource.NewStringProperty(value))
+	}
+	return resource.NewArrayProperty(items)
+}
+
+func getNameFromUrn(urn string) string {
+	if urn == "" {
+		return ""
+	}
+	return urn[strings.LastIndex(urn, "::")+2:]
+}
+
+// setStackConfig sets the stack config the program reads, clusters-config-path points at the fixture
+func setStackConfig(t *testing.T, values map[string]string) {
+	t.Helper()
+
+	stackConfig := map[string]string{"eks-iaac:clusters-config-path": filepath.Dir(testClusterDir)}
+	for key, value := range values {
+		stackConfig["eks-iaac:"+key] = value
+	}
+	encoded, err := json.Marshal(stackConfig)
+	require.NoError(t, err)
+	t.Setenv(pulumi.EnvConfig, string(encoded))
+}
+
+// testProgram is the program of main with the end of support of the cluster versions checked on a fixed date
+var testProgram = components.NewProgram(utils.TreeOptions{
+	SupportPolicy: utils.VersionPolicy{
+		WarningDays: utils.DefaultVersionWarningDays,
+		Now:         func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) },
+	},
+})
+
+// runProgram runs the program of main against the mocks, for the fixture cluster with its nodegroups, fargate profiles and addons
+func runProgram(t *testing.T) *mocks {
+	t.Helper()
+
+	setStackConfig(t, nil)
+	m := &mocks{}
+	err := pulumi.RunErr(testProgram, pulumi.WithMocks("eks-iaac", "test", m))
+	require.NoError(t, err)
+
+	return m
+}
+
+// getStatement decodes the first statement of a JSON policy document
+func getStatement(t *testing.T, policy resource.PropertyValue) map[string]interface{} {
+	t.Helper()
+	var document struct {
+		Statement []map[string]interface{}
+	}
+	require.NoError(t, json.Unmarshal([]byte(policy.StringValue()), &document))
+	require.NotEmpty(t, document.Statement)
+	return document.Statement[0]
+}
+
+func TestCreateOrUpdateClusters(t *testing.T) {
+	m := runProgram(t)
+
+	t.Run("TestClusterIsParentedByComponent", func(t *testing.T) {
+		component := m.get(t, "eks-iaac:components:EksCluster", "test-cluster")
+		require.False(t, component.Custom)
+
+		cluster := m.get(t, "aws:eks/cluster:Cluster", "test-cluster")
+		require.Equal(t, "test-cluster", cluster.Parent)
+		// the alias keeps the URN of clusters created before the component existed
+		require.Equal(t, 1, cluster.Aliases)
+
+		role := m.get(t, "aws:iam/role:Role", "test-cluster-eks-cluster-role")
+		require.Equal(t, "test-cluster", role.Parent)
+		require.Equal(t, 1, role.Aliases)
+	})
+
+	t.Run("TestClusterInputs", func(t *testing.T) {
+		cluster := m.get(t, "aws:eks/cluster:Cluster", "test-cluster")
+		require.Equal(t, "1.29", cluster.Inputs["version"].StringValue())
+		require.Equal(t, "arn:aws:iam::123456789012:role/test-cluster-eks-cluster-role", cluster.Inputs["roleArn"].StringValue())
+
+		vpcConfig := cluster.Inputs["vpcConfig"].ObjectValue()
+		require.Len(t, vpcConfig["subnetIds"].ArrayValue(), 2)
+		require.Equal(t, "10.0.0.0/8", vpcConfig["publicAccessCidrs"].ArrayValue()[0].StringValue())
+		require.True(t, vpcConfig["endpointPublicAccess"].BoolValue())
+		require.False(t, vpcConfig["endpointPrivateAccess"].BoolValue())
+
+		logTypes := cluster.Inputs["enabledClusterLogTypes"].ArrayValue()
+		require.Len(t, logTypes, 2)
+		require.Equal(t, "api", logTypes[0].StringValue())
+
+		encryptionConfig := cluster.Inputs["encryptionConfig"].ObjectValue()
+		require.Equal(t, "secrets", encryptionConfig["resources"].ArrayValue()[0].StringValue())
+		require.Contains(t, encryptionConfig["provider"].ObjectValue()["keyArn"].StringValue(), "test-cluster-eks-secrets-key")
+	})
+
+	t.Run("TestClusterDependencies", func(t *testing.T) {
+		cluster := m.get(t, "aws:eks/cluster:Cluster", "test-cluster")
+		require.Contains(t, cluster.Dependencies, "test-cluster-eks-cluster-role")
+		require.Contains(t, cluster.Dependencies, "test-cluster-cluster-logs")
+
+		logGroup := m.get(t, "aws:cloudwatch/logGroup:LogGroup", "test-cluster-cluster-logs")
+		require.Equal(t, "/aws/eks/test-cluster/cluster", logGroup.Inputs["name"].StringValue())
+		require.Equal(t, float64(30), logGroup.Inputs["retentionInDays"].NumberValue())
+	})
+
+	t.Run("TestClusterRoleTrustPolicy", func(t *testing.T) {
+		role := m.get(t, "aws:iam/role:Role", "test-cluster-eks-cluster-role")
+		statement := getStatement(t, role.Inputs["assumeRolePolicy"])
+		require.Equal(t, "sts:AssumeRole", statement["Action"])
+		require.Equal(t, map[string]interface{}{"Service": "eks.amazonaws.com"}, statement["Principal"])
+
+		attachment := m.get(t, "aws:iam/rolePolicyAttachment:RolePolicyAttachment", "test-cluster-eks-cluster-role-policy")
+		require.Equal(t, "arn:aws:iam::aws:policy/AmazonEKSClusterPolicy", attachment.Inputs["policyArn"].StringValue())
+	})
+
+	t.Run("TestServiceAccountRoleTrustPolicy", func(t *testing.T) {
+		provider := m.get(t, "aws:iam/openIdConnectProvider:OpenIdConnectProvider", "test-cluster-oidc-provider")
+		require.Equal(t, "https://oidc.eks.ap-south-1.amazonaws.com/id/EXAMPLE", provider.Inputs["url"].StringValue())
+		require.Equal(t, "sts.amazonaws.com", provider.Inputs["clientIdLists"].ArrayValue()[0].StringValue())
+		require.Equal(t, "my-app", provider.Inputs["clientIdLists"].ArrayValue()[1].StringValue())
+
+		role := m.get(t, "aws:iam/role:Role", "test-cluster-kube-system-cluster-autoscaler-irsa-role")
+		statement := getStatement(t, role.Inputs["assumeRolePolicy"])
+		require.Equal(t, "sts:AssumeRoleWithWebIdentity", statement["Action"])
+		require.Equal(t, map[string]interface{}{
+			"StringEquals": map[string]interface{}{
+				"oidc.eks.ap-south-1.amazonaws.com/id/EXAMPLE:sub": "system:serviceaccount:kube-system:cluster-autoscaler",
+				"oidc.eks.ap-south-1.amazonaws.com/id/EXAMPLE:aud": []interface{}{"sts.amazonaws.com", "my-app"},
+			},
+		}, statement["Condition"])
+	})
+
+	t.Run("TestClusterAccessEntries", func(t *testing.T) {
+		cluster := m.get(t, "aws:eks/cluster:Cluster", "test-cluster")
+		require.Equal(t, "API_AND_CONFIG_MAP", cluster.Inputs["accessConfig"].ObjectValue()["authenticationMode"].StringValue())
+
+		sre := m.get(t, "aws:eks/accessEntry:AccessEntry", "test-cluster-123456789012-role/sre")
+		require.Equal(t, "test-cluster", sre.Parent)
+		require.Equal(t, "arn:aws:iam::123456789012:role/sre", sre.Inputs["principalArn"].StringValue())
+		require.Equal(t, "STANDARD", sre.Inputs["type"].StringValue())
+		require.Contains(t, sre.Dependencies, "test-cluster")
+
+		admin := m.get(t, "aws:eks/accessPolicyAssociation:AccessPolicyAssociation", "test-cluster-123456789012-role/sre-AmazonEKSClusterAdminPolicy")
+		require.Equal(t, "arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy", admin.Inputs["policyArn"].StringValue())
+		require.Equal(t, "cluster", admin.Inputs["accessScope"].ObjectValue()["type"].StringValue())
+		require.Contains(t, admin.Dependencies, "test-cluster-123456789012-role/sre")
+
+		developers := m.get(t, "aws:eks/accessEntry:AccessEntry", "test-cluster-123456789012-role/teams/developers")
+		require.Equal(t, "developers", developers.Inputs["kubernetesGroups"].ArrayValue()[0].StringValue())
+		edit := m.get(t, "aws:eks/accessPolicyAssociation:AccessPolicyAssociation", "test-cluster-123456789012-role/teams/developers-AmazonEKSEditPolicy")
+		accessScope := edit.Inputs["accessScope"].ObjectValue()
+		require.Equal(t, "namespace", accessScope["type"].StringValue())
+		require.Equal(t, "dev", accessScope["namespaces"].ArrayValue()[0].StringValue())
+	})
+}
+
+func TestCreateOrUpdateNodeGroups(t *testing.T) {
+	m := runProgram(t)
+
+	t.Run("TestNodeGroupIsParentedByComponent", func(t *testing.T) {
+		component := m.get(t, "eks-iaac:components:ManagedNodeGroup", "test-cluster-on-demand")
+		require.Equal(t, "test-cluster", component.Parent)
+
+		nodeGroup := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+		require.Equal(t, "test-cluster-on-demand", nodeGroup.Parent)
+		// the nodegroup used to be named after the nodegroup alone, at the stack root and under the component
+		require.Equal(t, 3, nodeGroup.Aliases)
+		require.Contains(t, nodeGroup.Dependencies, "test-cluster")
+	})
+
+	t.Run("TestNodeGroupScalingAndUpdateConfig", func(t *testing.T) {
+		onDemand := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+		scalingConfig := onDemand.Inputs["scalingConfig"].ObjectValue()
+		require.Equal(t, float64(2), scalingConfig["desiredSize"].NumberValue())
+		require.Equal(t, float64(1), scalingConfig["minSize"].NumberValue())
+		require.Equal(t, float64(4), scalingConfig["maxSize"].NumberValue())
+		updateConfig := onDemand.Inputs["updateConfig"].ObjectValue()
+		require.Equal(t, float64(1), updateConfig["maxUnavailable"].NumberValue())
+		require.NotContains(t, updateConfig, resource.PropertyKey("maxUnavailablePercentage"))
+
+		spot := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot")
+		updateConfig = spot.Inputs["updateConfig"].ObjectValue()
+		require.Equal(t, float64(50), updateConfig["maxUnavailablePercentage"].NumberValue())
+		require.Equal(t, "SPOT", spot.Inputs["capacityType"].StringValue())
+	})
+
+	t.Run("TestNodeGroupVersions", func(t *testing.T) {
+		// the version of the cluster unless the nodegroup pins one
+		onDemand := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+		require.Equal(t, "1.29", onDemand.Inputs["version"].StringValue())
+		require.NotContains(t, onDemand.Inputs, resource.PropertyKey("releaseVersion"))
+		require.False(t, onDemand.Inputs["forceUpdateVersion"].BoolValue())
+
+		spot := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot")
+		require.Equal(t, "1.28", spot.Inputs["version"].StringValue())
+		require.Equal(t, "1.28.5-20240514", spot.Inputs["releaseVersion"].StringValue())
+		require.True(t, spot.Inputs["forceUpdateVersion"].BoolValue())
+	})
+
+	t.Run("TestNodeGroupUpgradeOrder", func(t *testing.T) {
+		// the nodegroups wait for the add-ons and are upgraded one at a time
+		onDemand := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+		require.Contains(t, onDemand.Dependencies, "test-cluster-vpc-cni")
+		require.NotContains(t, onDemand.Dependencies, "test-cluster-coredns")
+
+		// coredns needs the first nodegroup without taints, spot, so spot can not wait for it
+		spot := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot")
+		require.Contains(t, spot.Dependencies, "test-cluster-on-demand")
+		require.Contains(t, spot.Dependencies, "test-cluster-vpc-cni")
+		require.NotContains(t, spot.Dependencies, "test-cluster-coredns")
+	})
+
+	t.Run("TestNodeGroupTaints", func(t *testing.T) {
+		nodeGroup := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+		taints := nodeGroup.Inputs["taints"].ArrayValue()
+		require.Len(t, taints, 1)
+		require.Equal(t, "dedicated", taints[0].ObjectValue()["key"].StringValue())
+		require.Equal(t, "NO_SCHEDULE", taints[0].ObjectValue()["effect"].StringValue())
+	})
+
+	t.Run("TestNodeGroupRoleTrustPolicy", func(t *testing.T) {
+		role := m.get(t, "aws:iam/role:Role", "test-cluster-on-demand-eks-nodegroup-role")
+		statement := getStatement(t, role.Inputs["assumeRolePolicy"])
+		require.Equal(t, map[string]interface{}{"Service": "ec2.amazonaws.com"}, statement["Principal"])
+
+		nodeGroup := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+		require.Equal(t, "arn:aws:iam::123456789012:role/test-cluster-on-demand-eks-nodegroup-role", nodeGroup.Inputs["nodeRoleArn"].StringValue())
+
+		// an existing role is read instead of created
+		spot := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot")
+		require.Equal(t, "arn:aws:iam::123456789012:role/test-nodegroup-role", spot.Inputs["nodeRoleArn"].StringValue())
+		role = m.get(t, "aws:iam/role:Role", "test-cluster-spot-eks-nodegroup-role")
+		require.Equal(t, "arn:aws:iam::123456789012:role/test-nodegroup-role", role.ID)
+	})
+
+	t.Run("TestNodeGroupWithoutLaunchTemplate", func(t *testing.T) {
+		nodeGroup := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+		require.Equal(t, float64(20), nodeGroup.Inputs["diskSize"].NumberValue())
+		require.Equal(t, "test-key", nodeGroup.Inputs["remoteAccess"].ObjectValue()["ec2SshKey"].StringValue())
+		require.NotContains(t, nodeGroup.Inputs, resource.PropertyKey("launchTemplate"))
+	})
+
+	t.Run("TestNodeGroupWithLaunchTemplate", func(t *testing.T) {
+		launchTemplate := m.get(t, "aws:ec2/launchTemplate:LaunchTemplate", "test-cluster-spot-lt")
+		require.Equal(t, "test-cluster-spot", launchTemplate.Parent)
+		// the security groups of the network configuration are attached through the launch template
+		require.Equal(t, "sg-0123456789abcdef1", launchTemplate.Inputs["vpcSecurityGroupIds"].ArrayValue()[0].StringValue())
+		// the cluster security group is kept next to the ones of the nodegroup
+		require.Equal(t, "sg-0c1a55e7c1a55e7c1", launchTemplate.Inputs["vpcSecurityGroupIds"].ArrayValue()[1].StringValue())
+		require.Equal(t, "required", launchTemplate.Inputs["metadataOptions"].ObjectValue()["httpTokens"].StringValue())
+		ebs := launchTemplate.Inputs["blockDeviceMappings"].ArrayValue()[0].ObjectValue()["ebs"].ObjectValue()
+		require.Equal(t, "gp3", ebs["volumeType"].StringValue())
+		require.Equal(t, float64(250), ebs["throughput"].NumberValue())
+		require.NotEmpty(t, launchTemplate.Inputs["userData"].StringValue())
+
+		nodeGroup := m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot")
+		require.Equal(t, "test-cluster-spot-lt_id", nodeGroup.Inputs["launchTemplate"].ObjectValue()["id"].StringValue())
+		require.Equal(t, "1", nodeGroup.Inputs["launchTemplate"].ObjectValue()["version"].StringValue())
+		require.NotContains(t, nodeGroup.Inputs, resource.PropertyKey("diskSize"))
+		require.NotContains(t, nodeGroup.Inputs, resource.PropertyKey("remoteAccess"))
+		require.Contains(t, nodeGroup.Dependencies, "test-cluster-spot-lt")
+	})
+}
+
+func TestCreateOrUpdateFargateProfilesAndAddons(t *testing.T) {
+	m := runProgram(t)
+
+	t.Run("TestFargateProfilesAreCreatedOneAtATime", func(t *testing.T) {
+		require.Equal(t, 2, m.count("aws:eks/fargateProfile:FargateProfile"))
+		batch := m.get(t, "aws:eks/fargateProfile:FargateProfile", "test-cluster-batch")
+		require.Equal(t, "arn:aws:iam::123456789012:role/test-cluster-batch-eks-fargate-role", batch.Inputs["podExecutionRoleArn"].StringValue())
+
+		ci := m.get(t, "aws:eks/fargateProfile:FargateProfile", "test-cluster-ci")
+		require.Contains(t, ci.Dependencies, "test-cluster-batch")
+	})
+
+	t.Run("TestAddonDependencies", func(t *testing.T) {
+		coredns := m.get(t, "aws:eks/addon:Addon", "test-cluster-coredns")
+		require.Equal(t, "v1.11.1-eksbuild.4", coredns.Inputs["addonVersion"].StringValue())
+		require.Equal(t, `{"replicaCount":3}`, coredns.Inputs["configurationValues"].StringValue())
+		require.Contains(t, coredns.Dependencies, "test-cluster-on-demand")
+		require.Contains(t, coredns.Dependencies, "test-cluster-spot")
+
+		vpcCni := m.get(t, "aws:eks/addon:Addon", "test-cluster-vpc-cni")
+		require.NotContains(t, vpcCni.Dependencies, "test-cluster-on-demand")
+		// latest-compatible is resolved for the cluster version
+		require.Equal(t, "v1.18.3-eksbuild.2", vpcCni.Inputs["addonVersion"].StringValue())
+		require.Equal(t, "arn:aws:iam::123456789012:role/test-cluster-vpc-cni-irsa-role", vpcCni.Inputs["serviceAccountRoleArn"].StringValue())
+
+		kubeProxy := m.get(t, "aws:eks/addon:Addon", "test-cluster-kube-proxy")
+		require.NotContains(t, kubeProxy.Inputs, resource.PropertyKey("addonVersion"))
+	})
+
+	t.Run("TestAddonsRunOnTheDeployedNodes", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{
+			"test-cluster": resource.NewObjectProperty(resource.PropertyMap{"version": resource.NewStringProperty("1.29")}),
+			"nodeGroups": resource.NewObjectProperty(resource.PropertyMap{
+				"test-cluster/spot": resource.NewObjectProperty(resource.PropertyMap{"status": resource.NewStringProperty("ACTIVE")}),
+			}),
+		}}
+		require.NoError(t, runTargetedProgram(t, m, nil))
+
+		// coredns is upgraded before the nodegroups, on the nodes of the previous version
+		coredns := m.get(t, "aws:eks/addon:Addon", "test-cluster-coredns")
+		require.NotContains(t, coredns.Dependencies, "test-cluster-on-demand")
+		require.NotContains(t, coredns.Dependencies, "test-cluster-spot")
+		require.Contains(t, m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand").Dependencies, "test-cluster-coredns")
+		require.Contains(t, m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot").Dependencies, "test-cluster-coredns")
+	})
+
+	t.Run("TestUnpinnedAddonsAreRefusedOnUpgrade", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{
+			"test-cluster": resource.NewObjectProperty(resource.PropertyMap{"version": resource.NewStringProperty("1.28")}),
+		}}
+		err := runTargetedProgram(t, m, nil)
+		require.ErrorContains(t, err, filepath.Join(testClusterDir, "config.yaml")+":36:5: addons[2].version: add-on kube-proxy keeps its installed version when the cluster is upgraded from 1.28 to 1.29, pin a version of it for 1.29")
+		require.NotContains(t, err.Error(), "vpc-cni")
+		require.NotContains(t, err.Error(), "coredns")
+		require.Equal(t, 0, m.count("aws:eks/cluster:Cluster"))
+	})
+
+	t.Run("TestUpgradeIsCheckedAgainstTheLiveVersion", func(t *testing.T) {
+		// the cluster was deployed before the version was exported
+		m := &mocks{
+			stackOutputs:    resource.PropertyMap{"test-cluster": resource.NewObjectProperty(resource.PropertyMap{"name": resource.NewStringProperty("test-cluster")})},
+			clusterNames:    []string{"test-cluster"},
+			clusterVersions: map[string]string{"test-cluster": "1.28"},
+		}
+		err := runTargetedProgram(t, m, nil)
+		require.ErrorContains(t, err, "add-on kube-proxy keeps its installed version when the cluster is upgraded from 1.28 to 1.29")
+		require.Equal(t, 0, m.count("aws:eks/cluster:Cluster"))
+	})
+}
+
+// runTargetedProgram runs the program of main against the mocks with the given target stack config
+func runTargetedProgram(t *testing.T, m *mocks, stackConfig map[string]string) error {
+	t.Helper()
+
+	setStackConfig(t, stackConfig)
+	return pulumi.RunErr(testProgram, pulumi.WithMocks("eks-iaac", "test", m))
+}
+
+func TestSelectiveTargets(t *testing.T) {
+	// the last update created the cluster with only its spot nodegroup
+	last := &mocks{stackOutputs: resource.PropertyMap{}}
+	require.NoError(t, runTargetedProgram(t, last, map[string]string{"include-nodegroups": "spot"}))
+	deployedResources := resource.PropertyMap{
+		"test-cluster":      toPropertyArray(last.getResourceKeys("test-cluster")),
+		"test-cluster/spot": toPropertyArray(last.getResourceKeys("test-cluster-spot")),
+	}
+	deployed := resource.PropertyMap{
+		"test-cluster": resource.NewObjectProperty(resource.PropertyMap{"name": resource.NewStringProperty("test-cluster")}),
+		"nodeGroups": resource.NewObjectProperty(resource.PropertyMap{
+			"test-cluster/spot": resource.NewObjectProperty(resource.PropertyMap{"status": resource.NewStringProperty("ACTIVE")}),
+		}),
+		"resources": resource.NewObjectProperty(deployedResources),
+	}
+	// the nodegroups are created with their name as prefix
+	existingNodeGroups := map[string][]string{"test-cluster": {"spot20240601120000000000000001"}}
+
+	t.Run("TestExcludedDeployedClusterIsLeftUnchanged", func(t *testing.T) {
+		m := &mocks{stackOutputs: deployed, clusterNames: []string{"test-cluster"}, nodeGroupNames: existingNodeGroups}
+		require.NoError(t, runTargetedProgram(t, m, map[string]string{"exclude-clusters": "test-*"}))
+
+		// the cluster and every deployed resource under it are still registered so that none of them is deleted
+		require.Equal(t, []string{"*"}, m.get(t, "aws:eks/cluster:Cluster", "test-cluster").IgnoreChanges)
+		require.Equal(t, []string{"*"}, m.get(t, "aws:iam/role:Role", "test-cluster-eks-cluster-role").IgnoreChanges)
+		require.Equal(t, []string{"*"}, m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot").IgnoreChanges)
+
+		// the nodegroup that is not deployed is not created under the excluded cluster
+		require.Equal(t, 1, m.count("aws:eks/nodeGroup:NodeGroup"))
+	})
+
+	t.Run("TestResourcesAddedToAnExcludedClusterAreRefused", func(t *testing.T) {
+		// the access entries were added to the config after the last update
+		withoutAccess := resource.PropertyMap{}
+		for key, value := range deployedResources {
+			var resources []string
+			for _, item := range value.ArrayValue() {
+				if !strings.HasPrefix(item.StringValue(), "aws:eks/access") {
+					resources = append(resources, item.StringValue())
+				}
+			}
+			withoutAccess[key] = toPropertyArray(resources)
+		}
+		stackOutputs := deployed.Copy()
+		stackOutputs["resources"] = resource.NewObjectProperty(withoutAccess)
+
+		m := &mocks{stackOutputs: stackOutputs}
+		err := runTargetedProgram(t, m, map[string]string{"exclude-clusters": "test-*"})
+		require.ErrorContains(t, err, "cluster test-cluster is left unchanged but its config adds aws:eks/accessEntry:AccessEntry::test-cluster-123456789012-role/sre, ")
+		require.ErrorContains(t, err, "they would be created although it is excluded")
+		require.Equal(t, 0, m.count("aws:eks/accessEntry:AccessEntry"))
+	})
+
+	t.Run("TestResourcesRemovedFromAnExcludedNodeGroupAreRefused", func(t *testing.T) {
+		stackOutputs := deployed.Copy()
+		stackOutputs["resources"] = resource.NewObjectProperty(resource.PropertyMap{
+			"test-cluster/spot": toPropertyArray(append(last.getResourceKeys("test-cluster-spot"), "aws:ec2/launchTemplate:LaunchTemplate::spot-lt")),
+		})
+
+		m := &mocks{stackOutputs: stackOutputs}
+		err := runTargetedProgram(t, m, map[string]string{"include-nodegroups": "on-demand"})
+		require.ErrorContains(t, err, "nodegroup test-cluster/spot is left unchanged but its config removes aws:ec2/launchTemplate:LaunchTemplate::spot-lt since the last update, they would be deleted although it is excluded")
+	})
+
+	t.Run("TestExcludedClusterWithoutExportedResourcesIsRefused", func(t *testing.T) {
+		stackOutputs := deployed.Copy()
+		delete(stackOutputs, "resources")
+
+		m := &mocks{stackOutputs: stackOutputs}
+		err := runTargetedProgram(t, m, map[string]string{"exclude-clusters": "test-*"})
+		require.ErrorContains(t, err, "cluster test-cluster is left unchanged but its resources are missing from the outputs of the stack")
+	})
+
+	t.Run("TestExcludedClusterThatIsNotDeployedIsSkipped", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{}}
+		require.NoError(t, runTargetedProgram(t, m, map[string]string{"include-clusters": "prod-*"}))
+		require.Equal(t, 0, m.count("aws:eks/cluster:Cluster"))
+		require.Equal(t, 0, m.count("aws:eks/nodeGroup:NodeGroup"))
+	})
+
+	t.Run("TestExcludedClusterMissingFromTheOutputsIsRefused", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{}, clusterNames: []string{"test-cluster"}}
+		err := runTargetedProgram(t, m, map[string]string{"include-clusters": "prod-*"})
+		require.ErrorContains(t, err, "cluster test-cluster is excluded and missing from the outputs of the stack although it exists")
+		require.Equal(t, 0, m.count("aws:eks/cluster:Cluster"))
+	})
+
+	t.Run("TestIncludedNodeGroups", func(t *testing.T) {
+		m := &mocks{stackOutputs: deployed}
+		require.NoError(t, runTargetedProgram(t, m, map[string]string{"include-nodegroups": "test-cluster/on-*"}))
+
+		require.Empty(t, m.get(t, "aws:eks/cluster:Cluster", "test-cluster").IgnoreChanges)
+		require.Empty(t, m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand").IgnoreChanges)
+		require.Equal(t, []string{"*"}, m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-spot").IgnoreChanges)
+	})
+
+	t.Run("TestDeployedClusterVersions", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{
+			"test-cluster": resource.NewObjectProperty(resource.PropertyMap{"version": resource.NewStringProperty("1.28")}),
+			"old-cluster":  resource.NewObjectProperty(resource.PropertyMap{"name": resource.NewStringProperty("old-cluster")}),
+			"nodeGroups": resource.NewObjectProperty(resource.PropertyMap{
+				"removed-cluster/ng-1": resource.NewObjectProperty(resource.PropertyMap{"status": resource.NewStringProperty("ACTIVE")}),
+			}),
+		}}
+		var deployed components.DeployedResources
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			var err error
+			deployed, err = components.GetDeployedResources(ctx, []string{"new-cluster", "old-cluster", "test-cluster"})
+			return err
+		}, pulumi.WithMocks("eks-iaac", "test", m))
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{"new-cluster": false, "old-cluster": true, "removed-cluster": true, "test-cluster": true}, deployed.Clusters)
+		require.Equal(t, map[string]string{"test-cluster": "1.28"}, deployed.ClusterVersions)
+	})
+
+	t.Run("TestExcludedNodeGroupThatIsNotDeployedIsSkipped", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{}}
+		require.NoError(t, runTargetedProgram(t, m, map[string]string{"include-nodegroups": "on-demand"}))
+		require.Equal(t, 1, m.count("aws:eks/nodeGroup:NodeGroup"))
+		m.get(t, "aws:eks/nodeGroup:NodeGroup", "test-cluster-on-demand")
+	})
+
+	t.Run("TestExcludedNodeGroupMissingFromTheOutputsIsRefused", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{}, clusterNames: []string{"test-cluster"}, nodeGroupNames: existingNodeGroups}
+		err := runTargetedProgram(t, m, map[string]string{"include-nodegroups": "on-demand"})
+		require.ErrorContains(t, err, "nodegroup test-cluster/spot is excluded and missing from the outputs of the stack although it exists")
+		require.Equal(t, 0, m.count("aws:eks/nodeGroup:NodeGroup"))
+	})
+
+	t.Run("TestRemovedFilesOfExcludedResourcesAreRefused", func(t *testing.T) {
+		m := &mocks{stackOutputs: resource.PropertyMap{
+			"test-cluster": resource.NewObjectProperty(resource.PropertyMap{"name": resource.NewStringProperty("test-cluster")}),
+			"nodeGroups": resource.NewObjectProperty(resource.PropertyMap{
+				"test-cluster/retired": resource.NewObjectProperty(resource.PropertyMap{"status": resource.NewStringProperty("ACTIVE")}),
+				"legacy/ng-1":          resource.NewObjectProperty(resource.PropertyMap{"status": resource.NewStringProperty("ACTIVE")}),
+			}),
+		}}
+		err := runTargetedProgram(t, m, map[string]string{"include-nodegroups": "on-demand"})
+		require.ErrorContains(t, err, "nodegroup test-cluster/retired is deployed but its file was removed, it would be deleted although it is excluded: not matched by include-nodegroups")
+
+		err = runTargetedProgram(t, m, map[string]string{"exclude-clusters": "legacy"})
+		require.ErrorContains(t, err, `cluster legacy is deployed but its config was removed, it would be deleted although it is excluded: matched by exclude-clusters pattern "legacy"`)
+
+		// without targets the removed resources are deleted as usual
+		require.NoError(t, runTargetedProgram(t, m, nil))
+	})
+}
@@ -0,0 +1,69 @@
+package components
+
+import (
+	"log"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func createOrUpdateFargateProfile(ctx *pulumi.Context, fargateProfileConfig utils.FargateProfileConfig, cluster *eks.Cluster, podExecutionRole *iam.Role, clusterName string, opts ...pulumi.ResourceOption) (*eks.FargateProfile, error) {
+	log.Printf("Creating or updating fargate profile: %s", fargateProfileConfig.Name)
+
+	var selectors eks.FargateProfileSelectorArray
+	for _, selector := range fargateProfileConfig.Selectors {
+		selectors = append(selectors, eks.FargateProfileSelectorArgs{
+			Namespace: pulumi.String(selector.Namespace),
+			Labels:    utils.ConvertToPulumiStringMap(selector.Labels),
+		})
+	}
+
+	opts = append(opts, pulumi.DependsOn([]pulumi.Resource{cluster, podExecutionRole}))
+	fargateProfile, err := eks.NewFargateProfile(ctx, clusterName+"-"+fargateProfileConfig.Name, &eks.FargateProfileArgs{
+		ClusterName:         cluster.Name,
+		FargateProfileName:  pulumi.String(fargateProfileConfig.Name),
+		PodExecutionRoleArn: podExecutionRole.Arn,
+		Selectors:           selectors,
+		SubnetIds:           utils.ConvertToPulumiStringArray(fargateProfileConfig.SubnetIds),
+		Tags:                utils.ConvertToPulumiStringMap(fargateProfileConfig.Tags),
+	}, opts...)
+
+	if err != nil {
+		log.Printf("Failed to create or update fargate profile: %s", fargateProfileConfig.Name)
+		return nil, err
+	}
+
+	log.Printf("Successfully created or updated fargate profile: %s", fargateProfileConfig.Name)
+
+	return fargateProfile, nil
+}
+
+func CreateOrUpdateFargateProfiles(ctx *pulumi.Context, fargateProfileConfigs []utils.FargateProfileConfig, cluster *EksCluster, clusterName string) ([]*eks.FargateProfile, error) {
+	var fargateProfiles []*eks.FargateProfile
+
+	for _, fargateProfileConfig := range fargateProfileConfigs {
+		opts := []pulumi.ResourceOption{pulumi.Parent(cluster)}
+
+		// check if podExecutionRoleArn is empty, if so, create a new role with suffix "-eks-fargate-role"
+		podExecutionRole, err := getOrCreateFargatePodExecutionRole(ctx, fargateProfileConfig, clusterName, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		// EKS only creates or deletes one fargate profile of a cluster at a time
+		if len(fargateProfiles) > 0 {
+			opts = append(opts, pulumi.DependsOn([]pulumi.Resource{fargateProfiles[len(fargateProfiles)-1]}))
+		}
+
+		fargateProfile, err := createOrUpdateFargateProfile(ctx, fargateProfileConfig, cluster.Cluster, podExecutionRole, clusterName, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		fargateProfiles = append(fargateProfiles, fargateProfile)
+	}
+
+	return fargateProfiles, nil
+}
@@ -1,19 +1,21 @@
 package components
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
-	"github.com/pulumi/pulumi-aws/sdk/v4/go/aws/iam" // Add this import statement
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam" // Add this import statement
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-func createClusterRole(ctx *pulumi.Context, roleName string, clusterConfig utils.ClusterConfig) (*iam.Role, error) {
-    // Create the role
-    role, err := iam.NewRole(ctx, roleName, &iam.RoleArgs{
+func createClusterRole(ctx *pulumi.Context, roleName string, clusterConfig utils.ClusterConfig, opts ...pulumi.ResourceOption) (*iam.Role, error) {
+	// Create the role
+	role, err := iam.NewRole(ctx, roleName, &iam.RoleArgs{
 		Name: pulumi.String(roleName),
-        AssumeRolePolicy: pulumi.String(`{
+		AssumeRolePolicy: pulumi.String(`{
             "Version": "2012-10-17",
             "Statement": [
                 {
@@ -26,30 +28,29 @@ func createClusterRole(ctx *pulumi.Context, roleName string, clusterConfig utils
             ]
         }`),
 		Tags: utils.ConvertToPulumiStringMap(clusterConfig.Tags),
-    })
-    if err != nil {
-        return nil, err
-    }
-
-    // Attach the AmazonEKSClusterPolicy managed policy
-    _, err = iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-policy", roleName), &iam.RolePolicyAttachmentArgs{
-        Role:      role.Name,
-        PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonEKSClusterPolicy"),
-		
-    })
-    if err != nil {
-        return nil, err
-    }
-
-    return role, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attach the AmazonEKSClusterPolicy managed policy
+	_, err = iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-policy", roleName), &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonEKSClusterPolicy"),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
 }
 
 // create a function that abstract the creation or getting of the role
-func getOrCreateClusterRole(ctx *pulumi.Context, clusterConfig utils.ClusterConfig) (*iam.Role, error) {
+func getOrCreateClusterRole(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, opts ...pulumi.ResourceOption) (*iam.Role, error) {
 	clusterRoleName := clusterConfig.Name + "-eks-cluster-role"
 	if clusterConfig.RoleArn == "" {
 		log.Println("RoleArn is empty, creating a new role")
-		role, err := createClusterRole(ctx, clusterRoleName, clusterConfig)
+		role, err := createClusterRole(ctx, clusterRoleName, clusterConfig, opts...)
 		if err != nil {
 			log.Fatalf("Failed to create role for cluster: %s", clusterConfig.Name)
 			return nil, err
@@ -58,7 +59,7 @@ func getOrCreateClusterRole(ctx *pulumi.Context, clusterConfig utils.ClusterConf
 		return role, nil
 	} else {
 		log.Println("RoleArn exists, using the existing role")
-		role, err := iam.GetRole(ctx, clusterRoleName, pulumi.ID(clusterConfig.RoleArn), nil, nil)
+		role, err := iam.GetRole(ctx, clusterRoleName, pulumi.ID(clusterConfig.RoleArn), nil, opts...)
 		if err != nil {
 			log.Fatalf("Failed to get the existing role %s for cluster: %s", clusterConfig.RoleArn, clusterConfig.Name)
 			return nil, err
@@ -68,7 +69,7 @@ func getOrCreateClusterRole(ctx *pulumi.Context, clusterConfig utils.ClusterConf
 	}
 }
 
-func createNodeGroupRole(ctx *pulumi.Context, roleName string, nodeGroupConfig utils.NodeGroupConfig) (*iam.Role, error) {
+func createNodeGroupRole(ctx *pulumi.Context, roleName string, nodeGroupConfig utils.NodeGroupConfig, opts ...pulumi.ResourceOption) (*iam.Role, error) {
 	role, err := iam.NewRole(ctx, roleName, &iam.RoleArgs{
 		Name: pulumi.String(roleName),
 		AssumeRolePolicy: pulumi.String(`{
@@ -84,7 +85,7 @@ func createNodeGroupRole(ctx *pulumi.Context, roleName string, nodeGroupConfig u
             ]
         }`),
 		Tags: utils.ConvertToPulumiStringMap(nodeGroupConfig.Tags),
-	})
+	}, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +93,7 @@ func createNodeGroupRole(ctx *pulumi.Context, roleName string, nodeGroupConfig u
 	_, err = iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-policy", roleName), &iam.RolePolicyAttachmentArgs{
 		Role:      role.Name,
 		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy"),
-	})
+	}, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +101,7 @@ func createNodeGroupRole(ctx *pulumi.Context, roleName string, nodeGroupConfig u
 	_, err = iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-policy-2", roleName), &iam.RolePolicyAttachmentArgs{
 		Role:      role.Name,
 		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy"),
-	})
+	}, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +109,7 @@ func createNodeGroupRole(ctx *pulumi.Context, roleName string, nodeGroupConfig u
 	_, err = iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-policy-3", roleName), &iam.RolePolicyAttachmentArgs{
 		Role:      role.Name,
 		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly"),
-	})
+	}, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -116,13 +117,13 @@ func createNodeGroupRole(ctx *pulumi.Context, roleName string, nodeGroupConfig u
 	return role, nil
 }
 
-func getOrCreateNodeGroupRole(ctx *pulumi.Context, nodeGroupConfig utils.NodeGroupConfig, clusterName string) (*iam.Role, error) {
+func getOrCreateNodeGroupRole(ctx *pulumi.Context, nodeGroupConfig utils.NodeGroupConfig, clusterName string, opts ...pulumi.ResourceOption) (*iam.Role, error) {
 	// the nodegroup role name should add the cluster name to make it unique
 	nodeGroupRoleName := clusterName + "-" + nodeGroupConfig.Name + "-eks-nodegroup-role"
 	// log.Println("NodeGroupRoleName: ", nodeGroupRoleName)
 	if nodeGroupConfig.RoleArn == "" {
 		log.Println("RoleArn is empty, creating a new role")
-		role, err := createNodeGroupRole(ctx, nodeGroupRoleName, nodeGroupConfig)
+		role, err := createNodeGroupRole(ctx, nodeGroupRoleName, nodeGroupConfig, opts...)
 		if err != nil {
 			log.Fatalf("Failed to create role for nodegroup: %s", nodeGroupConfig.Name)
 			return nil, err
@@ -131,7 +132,7 @@ func getOrCreateNodeGroupRole(ctx *pulumi.Context, nodeGroupConfig utils.NodeGro
 		return role, nil
 	} else {
 		log.Println("RoleArn exists, using the existing role")
-		role, err := iam.GetRole(ctx, nodeGroupRoleName, pulumi.ID(nodeGroupConfig.RoleArn), nil, nil)
+		role, err := iam.GetRole(ctx, nodeGroupRoleName, pulumi.ID(nodeGroupConfig.RoleArn), nil, opts...)
 		if err != nil {
 			log.Fatalf("Failed to get the existing role %s for nodegroup: %s", nodeGroupConfig.RoleArn, nodeGroupConfig.Name)
 			return nil, err
@@ -139,4 +140,130 @@ func getOrCreateNodeGroupRole(ctx *pulumi.Context, nodeGroupConfig utils.NodeGro
 		log.Println("Successfully got the existing role")
 		return role, nil
 	}
-}
\ No newline at end of file
+}
+
+// getServiceAccountRoleTrustPolicy returns a trust policy that only lets the given
+// service account assume the role through the cluster's OIDC provider, with a token for one of its client IDs
+func getServiceAccountRoleTrustPolicy(providerArn, issuer string, clientIds []string, namespace, serviceAccountName string) (string, error) {
+	issuerHostPath := strings.TrimPrefix(issuer, "https://")
+	var audience interface{} = clientIds
+	if len(clientIds) == 1 {
+		audience = clientIds[0]
+	}
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect": "Allow",
+				"Principal": map[string]string{
+					"Federated": providerArn,
+				},
+				"Action": "sts:AssumeRoleWithWebIdentity",
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]interface{}{
+						issuerHostPath + ":sub": fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName),
+						issuerHostPath + ":aud": audience,
+					},
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func createServiceAccountRole(ctx *pulumi.Context, roleName string, serviceAccountRole utils.ServiceAccountRole, provider *iam.OpenIdConnectProvider, oidcIssuer pulumi.StringOutput, clientIds []string, tags map[string]string, opts ...pulumi.ResourceOption) (*iam.Role, error) {
+	assumeRolePolicy := pulumi.All(provider.Arn, oidcIssuer).ApplyT(func(args []interface{}) (string, error) {
+		return getServiceAccountRoleTrustPolicy(args[0].(string), args[1].(string), clientIds, serviceAccountRole.Namespace, serviceAccountRole.ServiceAccountName)
+	}).(pulumi.StringOutput)
+
+	roleArgs := &iam.RoleArgs{
+		Name:             pulumi.String(roleName),
+		AssumeRolePolicy: assumeRolePolicy,
+		Tags:             utils.ConvertToPulumiStringMap(tags),
+	}
+	if serviceAccountRole.InlinePolicy != "" {
+		roleArgs.InlinePolicies = iam.RoleInlinePolicyArray{
+			iam.RoleInlinePolicyArgs{
+				Name:   pulumi.String(roleName + "-inline-policy"),
+				Policy: pulumi.String(serviceAccountRole.InlinePolicy),
+			},
+		}
+	}
+
+	role, err := iam.NewRole(ctx, roleName, roleArgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, policyArn := range serviceAccountRole.ManagedPolicyArns {
+		_, err = iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-policy-%d", roleName, i+1), &iam.RolePolicyAttachmentArgs{
+			Role:      role.Name,
+			PolicyArn: pulumi.String(policyArn),
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return role, nil
+}
+
+func createFargatePodExecutionRole(ctx *pulumi.Context, roleName string, fargateProfileConfig utils.FargateProfileConfig, opts ...pulumi.ResourceOption) (*iam.Role, error) {
+	role, err := iam.NewRole(ctx, roleName, &iam.RoleArgs{
+		Name: pulumi.String(roleName),
+		AssumeRolePolicy: pulumi.String(`{
+			"Version": "2012-10-17",
+			"Statement": [
+				{
+					"Effect": "Allow",
+					"Principal": {
+						"Service": "eks-fargate-pods.amazonaws.com"
+					},
+					"Action": "sts:AssumeRole"
+				}
+			]
+		}`),
+		Tags: utils.ConvertToPulumiStringMap(fargateProfileConfig.Tags),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-policy", roleName), &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonEKSFargatePodExecutionRolePolicy"),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func getOrCreateFargatePodExecutionRole(ctx *pulumi.Context, fargateProfileConfig utils.FargateProfileConfig, clusterName string, opts ...pulumi.ResourceOption) (*iam.Role, error) {
+	// the pod execution role name should add the cluster name to make it unique
+	podExecutionRoleName := clusterName + "-" + fargateProfileConfig.Name + "-eks-fargate-role"
+	if fargateProfileConfig.PodExecutionRoleArn == "" {
+		log.Println("PodExecutionRoleArn is empty, creating a new role")
+		role, err := createFargatePodExecutionRole(ctx, podExecutionRoleName, fargateProfileConfig, opts...)
+		if err != nil {
+			log.Printf("Failed to create pod execution role for fargate profile: %s", fargateProfileConfig.Name)
+			return nil, err
+		}
+		log.Println("Role creation successful")
+		return role, nil
+	} else {
+		log.Println("PodExecutionRoleArn exists, using the existing role")
+		role, err := iam.GetRole(ctx, podExecutionRoleName, pulumi.ID(fargateProfileConfig.PodExecutionRoleArn), nil, opts...)
+		if err != nil {
+			log.Printf("Failed to get the existing role %s for fargate profile: %s", fargateProfileConfig.PodExecutionRoleArn, fargateProfileConfig.Name)
+			return nil, err
+		}
+		log.Println("Successfully got the existing role")
+		return role, nil
+	}
+}
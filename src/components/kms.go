@@ -0,0 +1,103 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// getClusterKeyPolicy returns a key policy that keeps the account in control of the key
+// and lets the cluster role use it for envelope encryption
+func getClusterKeyPolicy(clusterRoleArn string) (string, error) {
+	accountId := getAccountIdFromArn(clusterRoleArn)
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":    "EnableAccountAdministration",
+				"Effect": "Allow",
+				"Principal": map[string]string{
+					"AWS": fmt.Sprintf("arn:aws:iam::%s:root", accountId),
+				},
+				"Action":   "kms:*",
+				"Resource": "*",
+			},
+			{
+				"Sid":    "AllowClusterRoleUse",
+				"Effect": "Allow",
+				"Principal": map[string]string{
+					"AWS": clusterRoleArn,
+				},
+				"Action": []string{
+					"kms:Encrypt",
+					"kms:Decrypt",
+					"kms:ReEncrypt*",
+					"kms:GenerateDataKey*",
+					"kms:DescribeKey",
+					"kms:CreateGrant",
+					"kms:ListGrants",
+				},
+				"Resource": "*",
+			},
+		},
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func createClusterEncryptionKey(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, clusterRole *iam.Role, opts ...pulumi.ResourceOption) (*kms.Key, error) {
+	keyName := clusterConfig.Name + "-eks-secrets-key"
+	log.Printf("Creating KMS key: %s", keyName)
+
+	deletionWindowInDays := clusterConfig.Encryption.DeletionWindowInDays
+	if deletionWindowInDays == 0 {
+		deletionWindowInDays = 30
+	}
+
+	key, err := kms.NewKey(ctx, keyName, &kms.KeyArgs{
+		Description:          pulumi.String(fmt.Sprintf("Envelope encryption of the Kubernetes secrets of EKS cluster %s", clusterConfig.Name)),
+		EnableKeyRotation:    pulumi.Bool(true),
+		DeletionWindowInDays: pulumi.Int(deletionWindowInDays),
+		Policy:               clusterRole.Arn.ApplyT(getClusterKeyPolicy).(pulumi.StringOutput),
+		Tags:                 utils.ConvertToPulumiStringMap(clusterConfig.Tags),
+	}, opts...)
+	if err != nil {
+		log.Printf("Failed to create KMS key: %s", keyName)
+		return nil, err
+	}
+
+	_, err = kms.NewAlias(ctx, keyName+"-alias", &kms.AliasArgs{
+		Name:        pulumi.String(fmt.Sprintf("alias/eks/%s", clusterConfig.Name)),
+		TargetKeyId: key.KeyId,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Successfully created KMS key: %s", keyName)
+
+	return key, nil
+}
+
+// getOrCreateClusterEncryptionKeyArn returns the ARN of the configured KMS key or of a dedicated new key
+func getOrCreateClusterEncryptionKeyArn(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, clusterRole *iam.Role, opts ...pulumi.ResourceOption) (pulumi.StringOutput, error) {
+	if clusterConfig.Encryption.KmsKeyArn != "" {
+		log.Println("KmsKeyArn exists, using the existing key")
+		return pulumi.String(clusterConfig.Encryption.KmsKeyArn).ToStringOutput(), nil
+	}
+
+	log.Println("KmsKeyArn is empty, creating a new key")
+	key, err := createClusterEncryptionKey(ctx, clusterConfig, clusterRole, opts...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+	return key.Arn, nil
+}
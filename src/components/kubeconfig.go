@@ -0,0 +1,47 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const kubeconfigTemplate = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %[1]s
+    certificate-authority-data: %[2]s
+  name: %[3]s
+contexts:
+- context:
+    cluster: %[3]s
+    user: %[3]s
+  name: %[3]s
+current-context: %[3]s
+preferences: {}
+users:
+- name: %[3]s
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args:
+      - eks
+      - get-token
+      - --cluster-name
+      - %[4]s
+      - --region
+      - %[5]s
+      interactiveMode: Never
+`
+
+// generateKubeconfig builds a kubeconfig that authenticates through `aws eks get-token`,
+// the same shape that `aws eks update-kubeconfig` writes
+func generateKubeconfig(clusterName, clusterArn, endpoint, certificateAuthorityData pulumi.StringOutput) pulumi.StringOutput {
+	return pulumi.All(clusterName, clusterArn, endpoint, certificateAuthorityData).ApplyT(func(args []interface{}) string {
+		name := args[0].(string)
+		arn := args[1].(string)
+		return fmt.Sprintf(kubeconfigTemplate, args[2].(string), args[3].(string), arn, name, getRegionFromArn(arn))
+	}).(pulumi.StringOutput)
+}
@@ -0,0 +1,142 @@
+package components
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const userDataBoundary = "//"
+
+func createLaunchTemplate(ctx *pulumi.Context, nodeGroupConfig utils.NodeGroupConfig, clusterName string, clusterSecurityGroupId pulumi.StringInput, opts ...pulumi.ResourceOption) (*ec2.LaunchTemplate, error) {
+	launchTemplateName := clusterName + "-" + nodeGroupConfig.Name + "-lt"
+	log.Printf("Creating launch template: %s", launchTemplateName)
+
+	launchTemplateConfig := nodeGroupConfig.LaunchTemplate
+
+	metadataOptions := &ec2.LaunchTemplateMetadataOptionsArgs{
+		HttpEndpoint:            pulumi.String("enabled"),
+		HttpTokens:              pulumi.String("required"),
+		HttpPutResponseHopLimit: pulumi.Int(2),
+	}
+	if launchTemplateConfig.MetadataOptions.HttpTokens != "" {
+		metadataOptions.HttpTokens = pulumi.String(launchTemplateConfig.MetadataOptions.HttpTokens)
+	}
+	if launchTemplateConfig.MetadataOptions.HttpPutResponseHopLimit != 0 {
+		metadataOptions.HttpPutResponseHopLimit = pulumi.Int(launchTemplateConfig.MetadataOptions.HttpPutResponseHopLimit)
+	}
+
+	// the tags are propagated to the instances, volumes and network interfaces started from the template
+	var tagSpecifications ec2.LaunchTemplateTagSpecificationArray
+	for _, resourceType := range []string{"instance", "volume", "network-interface"} {
+		tagSpecifications = append(tagSpecifications, ec2.LaunchTemplateTagSpecificationArgs{
+			ResourceType: pulumi.String(resourceType),
+			Tags:         utils.ConvertToPulumiStringMap(nodeGroupConfig.Tags),
+		})
+	}
+
+	launchTemplateArgs := &ec2.LaunchTemplateArgs{
+		Name:                 pulumi.String(launchTemplateName),
+		BlockDeviceMappings:  getBlockDeviceMappingArgs(launchTemplateConfig.BlockDeviceMappings),
+		MetadataOptions:      metadataOptions,
+		TagSpecifications:    tagSpecifications,
+		Tags:                 utils.ConvertToPulumiStringMap(nodeGroupConfig.Tags),
+		UpdateDefaultVersion: pulumi.Bool(true),
+	}
+	// EKS only attaches the cluster security group when the template has none, the nodes need it to reach the control plane
+	if securityGroupIds := nodeGroupConfig.NetworkConfiguration.SecurityGroupIds; len(securityGroupIds) > 0 {
+		launchTemplateArgs.VpcSecurityGroupIds = append(utils.ConvertToPulumiStringArray(securityGroupIds), clusterSecurityGroupId)
+	}
+	if launchTemplateConfig.ImageId != "" {
+		launchTemplateArgs.ImageId = pulumi.String(launchTemplateConfig.ImageId)
+	}
+	if launchTemplateConfig.KeyName != "" {
+		launchTemplateArgs.KeyName = pulumi.String(launchTemplateConfig.KeyName)
+	}
+	if launchTemplateConfig.UserData != "" {
+		launchTemplateArgs.UserData = pulumi.String(generateUserData(nodeGroupConfig.ComputeConfiguration.AmiType, launchTemplateConfig.UserData))
+	}
+
+	launchTemplate, err := ec2.NewLaunchTemplate(ctx, launchTemplateName, launchTemplateArgs, opts...)
+	if err != nil {
+		log.Printf("Failed to create launch template: %s", launchTemplateName)
+		return nil, err
+	}
+
+	log.Printf("Successfully created launch template: %s", launchTemplateName)
+
+	return launchTemplate, nil
+}
+
+func getBlockDeviceMappingArgs(blockDeviceMappings []utils.BlockDeviceMapping) ec2.LaunchTemplateBlockDeviceMappingArray {
+	var result ec2.LaunchTemplateBlockDeviceMappingArray
+	for _, blockDeviceMapping := range blockDeviceMappings {
+		volumeType := blockDeviceMapping.VolumeType
+		if volumeType == "" {
+			volumeType = "gp3"
+		}
+
+		ebs := &ec2.LaunchTemplateBlockDeviceMappingEbsArgs{
+			VolumeSize:          pulumi.Int(blockDeviceMapping.VolumeSize),
+			VolumeType:          pulumi.String(volumeType),
+			Encrypted:           pulumi.String(strconv.FormatBool(blockDeviceMapping.Encrypted)),
+			DeleteOnTermination: pulumi.String("true"),
+		}
+		if blockDeviceMapping.Iops != 0 {
+			ebs.Iops = pulumi.Int(blockDeviceMapping.Iops)
+		}
+		if blockDeviceMapping.Throughput != 0 {
+			ebs.Throughput = pulumi.Int(blockDeviceMapping.Throughput)
+		}
+		if blockDeviceMapping.KmsKeyId != "" {
+			ebs.KmsKeyId = pulumi.String(blockDeviceMapping.KmsKeyId)
+		}
+
+		result = append(result, ec2.LaunchTemplateBlockDeviceMappingArgs{
+			DeviceName: pulumi.String(blockDeviceMapping.DeviceName),
+			Ebs:        ebs,
+		})
+	}
+	return result
+}
+
+// generateUserData returns the base64 encoded user data in the form EKS merges with its own bootstrap:
+// MIME multipart with a shell script for AL2, MIME multipart with a nodeadm NodeConfig or a shell script
+// for AL2023 and the raw TOML settings for Bottlerocket
+func generateUserData(amiType, userData string) string {
+	if strings.HasPrefix(amiType, "BOTTLEROCKET") {
+		return base64.StdEncoding.EncodeToString([]byte(userData))
+	}
+
+	contentType := `text/x-shellscript; charset="us-ascii"`
+	if strings.HasPrefix(amiType, "AL2023") && strings.HasPrefix(strings.TrimSpace(userData), "apiVersion: node.eks.aws") {
+		contentType = "application/node.eks.aws"
+	}
+
+	var builder strings.Builder
+	builder.WriteString("MIME-Version: 1.0\n")
+	builder.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\n\n", userDataBoundary))
+	builder.WriteString(fmt.Sprintf("--%s\n", userDataBoundary))
+	builder.WriteString(fmt.Sprintf("Content-Type: %s\n\n", contentType))
+	builder.WriteString(strings.TrimRight(userData, "\n"))
+	builder.WriteString(fmt.Sprintf("\n\n--%s--\n", userDataBoundary))
+
+	return base64.StdEncoding.EncodeToString([]byte(builder.String()))
+}
+
+// getNodeGroupLaunchTemplateArgs points the nodegroup at the latest version of the launch template
+func getNodeGroupLaunchTemplateArgs(launchTemplate *ec2.LaunchTemplate) *eks.NodeGroupLaunchTemplateArgs {
+	return &eks.NodeGroupLaunchTemplateArgs{
+		Id: launchTemplate.ID().ToStringOutput(),
+		Version: launchTemplate.LatestVersion.ApplyT(func(version int) string {
+			return strconv.Itoa(version)
+		}).(pulumi.StringOutput),
+	}
+}
@@ -0,0 +1,40 @@
+package components
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// createClusterLogGroup creates the log group EKS writes the control plane logs to. EKS created the group of a
+// cluster that already logged, it has to be imported before logRetentionInDays or logKmsKeyArn is set:
+//
+//	pulumi import aws:cloudwatch/logGroup:LogGroup <cluster>-cluster-logs /aws/eks/<cluster>/cluster --parent <urn of the EksCluster>
+func createClusterLogGroup(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, opts ...pulumi.ResourceOption) (*cloudwatch.LogGroup, error) {
+	logGroupName := fmt.Sprintf("/aws/eks/%s/cluster", clusterConfig.Name)
+	log.Printf("Creating log group: %s", logGroupName)
+
+	logGroupArgs := &cloudwatch.LogGroupArgs{
+		Name: pulumi.String(logGroupName),
+		Tags: utils.ConvertToPulumiStringMap(clusterConfig.Tags),
+	}
+	if clusterConfig.LogRetentionInDays != 0 {
+		logGroupArgs.RetentionInDays = pulumi.Int(clusterConfig.LogRetentionInDays)
+	}
+	if clusterConfig.LogKmsKeyArn != "" {
+		logGroupArgs.KmsKeyId = pulumi.String(clusterConfig.LogKmsKeyArn)
+	}
+
+	logGroup, err := cloudwatch.NewLogGroup(ctx, clusterConfig.Name+"-cluster-logs", logGroupArgs, opts...)
+	if err != nil {
+		log.Printf("Failed to create log group: %s", logGroupName)
+		return nil, err
+	}
+
+	log.Printf("Successfully created log group: %s", logGroupName)
+
+	return logGroup, nil
+}
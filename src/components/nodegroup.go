@@ -4,36 +4,135 @@ import (
 	"log"
 
 	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
-	"github.com/pulumi/pulumi-aws/sdk/v4/go/aws/eks"
-	"github.com/pulumi/pulumi-aws/sdk/v4/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-func createOrUpdateNodeGroup(ctx *pulumi.Context, nodeGroupConfig utils.NodeGroupConfig, cluster *eks.Cluster, nodeGroupRole *iam.Role) (*eks.NodeGroup, error) {
+// ManagedNodeGroup is a component resource that groups the node role, its
+// policy attachments and the EKS managed nodegroup under a single node in the stack.
+type ManagedNodeGroup struct {
+	pulumi.ResourceState
+
+	Name           string
+	NodeGroup      *eks.NodeGroup
+	Role           *iam.Role
+	LaunchTemplate *ec2.LaunchTemplate
+
+	Arn     pulumi.StringOutput `pulumi:"arn"`
+	Status  pulumi.StringOutput `pulumi:"status"`
+	RoleArn pulumi.StringOutput `pulumi:"roleArn"`
+}
+
+// NewManagedNodeGroup registers the ManagedNodeGroup component and all of its children,
+// the nodegroup waits for the cluster and the resources of dependsOn.
+func NewManagedNodeGroup(ctx *pulumi.Context, name string, nodeGroupConfig utils.NodeGroupConfig, cluster *EksCluster, clusterName string, dependsOn []pulumi.Resource, opts ...pulumi.ResourceOption) (*ManagedNodeGroup, error) {
+	component := &ManagedNodeGroup{Name: nodeGroupConfig.Name}
+	err := ctx.RegisterComponentResource("eks-iaac:components:ManagedNodeGroup", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// children used to live at the stack root, the alias keeps their URNs stable
+	childOpts := []pulumi.ResourceOption{
+		pulumi.Parent(component),
+		pulumi.Aliases([]pulumi.Alias{{NoParent: pulumi.Bool(true)}}),
+	}
+
+	// check if roleArn is empty, if so, create a new role with suffix "-eks-nodegroup-role"
+	nodeGroupRole, err := getOrCreateNodeGroupRole(ctx, nodeGroupConfig, clusterName, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var launchTemplate *ec2.LaunchTemplate
+	if nodeGroupConfig.LaunchTemplate != nil {
+		launchTemplate, err = createLaunchTemplate(ctx, nodeGroupConfig, clusterName, cluster.SecurityGroupId, pulumi.Parent(component))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodeGroup, err := createOrUpdateNodeGroup(ctx, nodeGroupConfig, clusterName, cluster.Cluster, nodeGroupRole, launchTemplate, dependsOn, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	component.NodeGroup = nodeGroup
+	component.Role = nodeGroupRole
+	component.LaunchTemplate = launchTemplate
+	component.Arn = nodeGroup.Arn
+	component.Status = nodeGroup.Status
+	component.RoleArn = nodeGroupRole.Arn
+
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"arn":     component.Arn,
+		"status":  component.Status,
+		"roleArn": component.RoleArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return component, nil
+}
+
+func createOrUpdateNodeGroup(ctx *pulumi.Context, nodeGroupConfig utils.NodeGroupConfig, clusterName string, cluster *eks.Cluster, nodeGroupRole *iam.Role, launchTemplate *ec2.LaunchTemplate, dependsOn []pulumi.Resource, opts ...pulumi.ResourceOption) (*eks.NodeGroup, error) {
 	log.Printf("Creating or updating node group: %s", nodeGroupConfig.Name)
 
-	nodeGroup, err := eks.NewNodeGroup(ctx, nodeGroupConfig.Name, &eks.NodeGroupArgs{
-		ClusterName:   cluster.Name,
+	nodeGroupArgs := &eks.NodeGroupArgs{
+		ClusterName:         cluster.Name,
 		NodeGroupNamePrefix: pulumi.String(nodeGroupConfig.Name),
-		NodeRoleArn:   nodeGroupRole.Arn,
-		SubnetIds:     utils.ConvertToPulumiStringArray(nodeGroupConfig.NetworkConfiguration.SubnetIds),
+		NodeRoleArn:         nodeGroupRole.Arn,
+		SubnetIds:           utils.ConvertToPulumiStringArray(nodeGroupConfig.NetworkConfiguration.SubnetIds),
 		ScalingConfig: &eks.NodeGroupScalingConfigArgs{
 			DesiredSize: pulumi.Int(nodeGroupConfig.ScalingConfiguration.DesiredCapacity),
 			MinSize:     pulumi.Int(nodeGroupConfig.ScalingConfiguration.MinSize),
 			MaxSize:     pulumi.Int(nodeGroupConfig.ScalingConfiguration.MaxSize),
 		},
 		InstanceTypes: utils.ConvertToPulumiStringArray(nodeGroupConfig.ComputeConfiguration.InstanceTypes),
-		Tags: 		utils.ConvertToPulumiStringMap(nodeGroupConfig.Tags),
-		Labels: 	utils.ConvertToPulumiStringMap(nodeGroupConfig.KubernetesLabels),
-		Taints: 	utils.ConvertToPulumiTaintArray(nodeGroupConfig.KubernetesTaints),
-		DiskSize: pulumi.Int(nodeGroupConfig.ComputeConfiguration.DiskSize),
-		AmiType: pulumi.String(nodeGroupConfig.ComputeConfiguration.AmiType),
-		RemoteAccess: &eks.NodeGroupRemoteAccessArgs{
+		Tags:          utils.ConvertToPulumiStringMap(nodeGroupConfig.Tags),
+		Labels:        utils.ConvertToPulumiStringMap(nodeGroupConfig.KubernetesLabels),
+		Taints:        utils.ConvertToPulumiTaintArray(nodeGroupConfig.KubernetesTaints),
+		AmiType:       pulumi.String(nodeGroupConfig.ComputeConfiguration.AmiType),
+		CapacityType:  pulumi.String(nodeGroupConfig.ComputeConfiguration.CapacityType),
+		UpdateConfig:  getNodeGroupUpdateConfigArgs(nodeGroupConfig.ScalingConfiguration),
+	}
+
+	// the nodes follow the version of the control plane, so they are only upgraded once it is,
+	// a custom AMI brings its own version
+	computeConfig := nodeGroupConfig.ComputeConfiguration
+	if computeConfig.AmiType != "CUSTOM" {
+		if computeConfig.Version != "" {
+			nodeGroupArgs.Version = pulumi.String(computeConfig.Version)
+		} else {
+			nodeGroupArgs.Version = cluster.Version
+		}
+		if computeConfig.ReleaseVersion != "" {
+			nodeGroupArgs.ReleaseVersion = pulumi.String(computeConfig.ReleaseVersion)
+		}
+		nodeGroupArgs.ForceUpdateVersion = pulumi.Bool(computeConfig.ForceUpdateVersion)
+	}
+
+	// disk size and remote access are owned by the launch template when there is one
+	dependsOn = append([]pulumi.Resource{cluster}, dependsOn...)
+	if launchTemplate != nil {
+		nodeGroupArgs.LaunchTemplate = getNodeGroupLaunchTemplateArgs(launchTemplate)
+		dependsOn = append(dependsOn, launchTemplate)
+	} else {
+		nodeGroupArgs.DiskSize = pulumi.Int(nodeGroupConfig.ComputeConfiguration.DiskSize)
+		nodeGroupArgs.RemoteAccess = &eks.NodeGroupRemoteAccessArgs{
 			Ec2SshKey: pulumi.String(nodeGroupConfig.NetworkConfiguration.Ec2KeyPair),
-		},
-		CapacityType: pulumi.String(nodeGroupConfig.ComputeConfiguration.CapacityType),
-		UpdateConfig: getNodeGroupUpdateConfigArgs(nodeGroupConfig.ScalingConfiguration),
-	}, pulumi.DependsOn([]pulumi.Resource{cluster}))
+		}
+	}
+
+	// nodegroup names are only unique per cluster, the resource used to be named after the nodegroup alone
+	opts = append(opts, pulumi.DependsOn(dependsOn), pulumi.Aliases([]pulumi.Alias{
+		{Name: pulumi.String(nodeGroupConfig.Name)},
+		{Name: pulumi.String(nodeGroupConfig.Name), NoParent: pulumi.Bool(true)},
+	}))
+	nodeGroup, err := eks.NewNodeGroup(ctx, clusterName+"-"+nodeGroupConfig.Name, nodeGroupArgs, opts...)
 
 	if err != nil {
 		log.Printf("Failed to create or update node group: %s", nodeGroupConfig.Name)
@@ -45,24 +144,17 @@ func createOrUpdateNodeGroup(ctx *pulumi.Context, nodeGroupConfig utils.NodeGrou
 	return nodeGroup, nil
 }
 
-func CreateOrUpdateNodeGroups(ctx *pulumi.Context, nodeGroupConfigs []utils.NodeGroupConfig, cluster *eks.Cluster, clusterName string) error {
-	for _, nodeGroupConfig := range nodeGroupConfigs {
-		log.Printf("Creating or updating node group: %s", nodeGroupConfig.Name)
-
-		// check if roleArn is empty, if so, create a new role with suffix "-eks-nodegroup-role"
-		nodeGroupRole, err := getOrCreateNodeGroupRole(ctx, nodeGroupConfig, clusterName)
-		if err != nil {
-			return err
-		}
-
-		// Use the createOrUpdateNodeGroup function from src/components/nodegroup.go to create or update the nodegroup
-		_, err = createOrUpdateNodeGroup(ctx, nodeGroupConfig, cluster, nodeGroupRole)
-		if err != nil {
-			return err
+// GetNodeGroupOutputs returns the outputs of the nodegroups keyed by "<cluster>/<nodegroup>"
+func GetNodeGroupOutputs(clusterName string, nodeGroups []*ManagedNodeGroup) pulumi.Map {
+	outputs := pulumi.Map{}
+	for _, nodeGroup := range nodeGroups {
+		outputs[clusterName+"/"+nodeGroup.Name] = pulumi.Map{
+			"arn":     nodeGroup.Arn,
+			"roleArn": nodeGroup.RoleArn,
+			"status":  nodeGroup.Status,
 		}
 	}
-
-	return nil
+	return outputs
 }
 
 func getNodeGroupUpdateConfigArgs(scalingConfig utils.ScalingConfig) *eks.NodeGroupUpdateConfigArgs {
@@ -77,4 +169,4 @@ func getNodeGroupUpdateConfigArgs(scalingConfig utils.ScalingConfig) *eks.NodeGr
 			MaxUnavailablePercentage: pulumi.Int(scalingConfig.MaximumUnavailable.Value),
 		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,76 @@
+package components
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// oidcDialTimeout bounds the connection to the issuer, it runs during previews too
+const oidcDialTimeout = 10 * time.Second
+
+// thumbprint of the root CA that signs the EKS OIDC issuer certificates, used when
+// the issuer certificate chain can not be fetched
+const eksOidcRootCAThumbprint = "9e99a48a9960b14926bb7f3b02e22da2b0ab7280"
+
+func createOidcProvider(ctx *pulumi.Context, clusterConfig utils.ClusterConfig, oidcIssuer pulumi.StringOutput, opts ...pulumi.ResourceOption) (*iam.OpenIdConnectProvider, error) {
+	log.Printf("Creating OIDC provider for cluster: %s", clusterConfig.Name)
+
+	clientIds := clusterConfig.Oidc.GetClientIds()
+
+	var thumbprints pulumi.StringArrayInput
+	if len(clusterConfig.Oidc.Thumbprints) > 0 {
+		thumbprints = utils.ConvertToPulumiStringArray(clusterConfig.Oidc.Thumbprints)
+	} else {
+		thumbprints = oidcIssuer.ApplyT(func(issuer string) []string {
+			return []string{getOidcThumbprint(issuer)}
+		}).(pulumi.StringArrayOutput)
+	}
+
+	provider, err := iam.NewOpenIdConnectProvider(ctx, clusterConfig.Name+"-oidc-provider", &iam.OpenIdConnectProviderArgs{
+		Url:             oidcIssuer,
+		ClientIdLists:   utils.ConvertToPulumiStringArray(clientIds),
+		ThumbprintLists: thumbprints,
+		Tags:            utils.ConvertToPulumiStringMap(clusterConfig.Tags),
+	}, opts...)
+	if err != nil {
+		log.Printf("Failed to create OIDC provider for cluster: %s", clusterConfig.Name)
+		return nil, err
+	}
+
+	log.Printf("Successfully created OIDC provider for cluster: %s", clusterConfig.Name)
+
+	return provider, nil
+}
+
+// getOidcThumbprint returns the SHA-1 thumbprint of the root certificate served by the issuer
+func getOidcThumbprint(issuer string) string {
+	issuerUrl, err := url.Parse(issuer)
+	if err != nil || issuerUrl.Host == "" {
+		return eksOidcRootCAThumbprint
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: oidcDialTimeout}, "tcp", issuerUrl.Host+":443", &tls.Config{})
+	if err != nil {
+		log.Printf("Failed to fetch the certificate of %s, using the EKS root CA thumbprint: %v", issuer, err)
+		return eksOidcRootCAThumbprint
+	}
+	defer conn.Close()
+
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return eksOidcRootCAThumbprint
+	}
+
+	sum := sha1.Sum(certificates[len(certificates)-1].Raw)
+	return strings.ToLower(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,139 @@
+package components
+
+import (
+	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// NewProgram returns the body of the pulumi program run by main, it creates the clusters of the clusters-config-path
+// stack config directory with their nodegroups, add-ons and fargate profiles, the stack config overrides the options
+func NewProgram(options utils.TreeOptions) pulumi.RunFunc {
+	return func(ctx *pulumi.Context) error {
+		return program(ctx, options)
+	}
+}
+
+func program(ctx *pulumi.Context, options utils.TreeOptions) (err error) {
+	// Create a new config object for the current Pulumi stack
+	conf := config.New(ctx, "")
+
+	// Read the root directory path from the Pulumi config
+	rootDir := conf.Require("clusters-config-path")
+
+	// The version-warning-days stack config sets how long before the end of standard support a cluster version is warned about
+	if conf.Get("version-warning-days") != "" {
+		days, err := conf.TryInt("version-warning-days")
+		if err != nil {
+			return err
+		}
+		options.SupportPolicy.WarningDays = days
+	}
+
+	// The check-cluster-dir-names stack config requires the name of every cluster to match its directory
+	if conf.Get("check-cluster-dir-names") != "" {
+		check, err := conf.TryBool("check-cluster-dir-names")
+		if err != nil {
+			return err
+		}
+		options.CheckClusterDirNames = check
+	}
+
+	// Read and validate every cluster, nodegroup and fargate profile file first, and the nodegroups against their cluster,
+	// so that all problems are reported at once, the configs are not read again afterwards
+	tree, err := utils.ValidateConfigTree(rootDir, options)
+	if err != nil {
+		return err
+	}
+	clusterConfigs := tree.Clusters
+
+	// The include-clusters, exclude-clusters and include-nodegroups stack config keys limit the run to some clusters and nodegroups
+	targets, err := utils.NewTargetConfig(conf.Get("include-clusters"), conf.Get("exclude-clusters"), conf.Get("include-nodegroups"))
+	if err != nil {
+		return err
+	}
+
+	// The outputs of the last update tell which clusters and nodegroups are deployed, with which versions and resources,
+	// they are only read for the version checks of the configured clusters and for the targets
+	deployed := DeployedResources{}
+	if len(clusterConfigs) > 0 || !targets.IsEmpty() {
+		deployed, err = GetDeployedResources(ctx, utils.GetClusterNames(clusterConfigs))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The deployed clusters exported without a version are checked against their live version, AWS is only
+	// listed for them and for the excluded clusters and nodegroups missing from the outputs
+	clusterExists := newClusterLookup(ctx)
+	err = deployed.LookupClusterVersions(ctx, utils.GetClusterNames(clusterConfigs), clusterExists)
+	if err != nil {
+		return err
+	}
+
+	// The control plane is upgraded one minor version at a time and never downgraded
+	err = utils.CheckVersionUpgrades(clusterConfigs, deployed.ClusterVersions)
+	if err != nil {
+		return err
+	}
+
+	// Excluded clusters and nodegroups that are deployed are still registered, unchanged, so that they are not deleted,
+	// those missing from the outputs are only skipped when they do not exist in AWS either
+	clusterConfigs, unchangedClusters, err := targets.SelectClusters(clusterConfigs, deployed.Clusters, clusterExists)
+	if err != nil {
+		return err
+	}
+
+	// The resources of the clusters and nodegroups left unchanged are held back until they are found deployed
+	tracker := NewResourceTracker()
+	defer func() {
+		tracker.Close(err)
+	}()
+
+	// Create the clusters
+	clusters, err := CreateOrUpdateClusters(ctx, clusterConfigs, unchangedClusters, tracker)
+	if err != nil {
+		return err
+	}
+
+	// Outputs of every nodegroup keyed by "<cluster>/<nodegroup>"
+	nodeGroupOutputs := pulumi.Map{}
+
+	// Iterate over the clusters by name
+	for _, name := range utils.GetClusterNames(clusterConfigs) {
+		clusterConfig, cluster := clusterConfigs[name], clusters[name]
+
+		// Export the endpoint, certificate and kubeconfig of the current cluster
+		ExportClusterOutputs(ctx, name, cluster)
+
+		// The nodegroups were read from the nodegroups directory next to the cluster config, those of an unchanged cluster are left unchanged with it
+		nodeGroupExists := newNodeGroupLookup(ctx, name, clusterExists)
+		nodeGroupConfigs, unchangedNodeGroups, err := targets.SelectNodeGroups(name, unchangedClusters[name], tree.NodeGroups[name], deployed.NodeGroups, nodeGroupExists)
+		if err != nil {
+			return err
+		}
+
+		// Create the add-ons and nodegroups of the current cluster, they are upgraded after the control plane one at a time,
+		// the add-ons first while the deployed nodes can run them
+		nodeGroups, _, err := CreateOrUpdateNodeGroupsAndAddons(ctx, clusterConfig, nodeGroupConfigs, cluster, unchangedNodeGroups, deployed.HasNodeGroups(name), tracker)
+		if err != nil {
+			return err
+		}
+
+		// Create the fargate profiles for the current cluster, they are optional and live next to its nodegroups
+		_, err = CreateOrUpdateFargateProfiles(ctx, tree.FargateProfiles[name], cluster, name)
+		if err != nil {
+			return err
+		}
+
+		for key, value := range GetNodeGroupOutputs(name, nodeGroups) {
+			nodeGroupOutputs[key] = value
+		}
+	}
+
+	ctx.Export("nodeGroups", nodeGroupOutputs)
+	ctx.Export("resources", tracker.Outputs())
+
+	// The clusters and nodegroups left unchanged must not have resources created or deleted
+	return tracker.Check(deployed.Resources)
+}
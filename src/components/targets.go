@@ -0,0 +1,286 @@
+package components
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// DeployedResources are the clusters and nodegroups exported by the last update of the current stack
+type DeployedResources struct {
+	Clusters        map[string]bool     // by cluster name
+	NodeGroups      map[string]bool     // by "<cluster>/<nodegroup>"
+	ClusterVersions map[string]string   // Kubernetes version by cluster name, missing for the clusters exported without one
+	Resources       map[string][]string // "<type>::<name>" of the resources under every cluster and nodegroup, by ResourceTracker key
+}
+
+// GetDeployedResources returns the clusters and nodegroups exported by the last update of the current stack,
+// read through a reference to the stack itself
+func GetDeployedResources(ctx *pulumi.Context, clusterNames []string) (DeployedResources, error) {
+	stackName := fmt.Sprintf("%s/%s/%s", ctx.Organization(), ctx.Project(), ctx.Stack())
+	log.Printf("Reading the deployed clusters and nodegroups from the outputs of stack: %s", stackName)
+
+	deployed := DeployedResources{Clusters: map[string]bool{}, NodeGroups: map[string]bool{}, ClusterVersions: map[string]string{}, Resources: map[string][]string{}}
+	stack, err := pulumi.NewStackReference(ctx, stackName, nil)
+	if err != nil {
+		return deployed, err
+	}
+
+	for _, clusterName := range clusterNames {
+		output, err := stack.GetOutputDetails(clusterName)
+		if err != nil {
+			return deployed, err
+		}
+		// the cluster outputs hold the kubeconfig, which makes them secret
+		value := output.Value
+		if value == nil {
+			value = output.SecretValue
+		}
+		deployed.Clusters[clusterName] = value != nil
+		if outputs, ok := value.(map[string]interface{}); ok {
+			if version, ok := outputs["version"].(string); ok {
+				deployed.ClusterVersions[clusterName] = version
+			}
+		}
+	}
+
+	output, err := stack.GetOutputDetails("nodeGroups")
+	if err != nil {
+		return deployed, err
+	}
+	value := output.Value
+	if value == nil {
+		value = output.SecretValue
+	}
+	if outputs, ok := value.(map[string]interface{}); ok {
+		for key := range outputs {
+			deployed.NodeGroups[key] = true
+			// the cluster of a deployed nodegroup is deployed too, even when its config was removed
+			deployed.Clusters[strings.SplitN(key, "/", 2)[0]] = true
+		}
+	}
+
+	output, err = stack.GetOutputDetails("resources")
+	if err != nil {
+		return deployed, err
+	}
+	value = output.Value
+	if value == nil {
+		value = output.SecretValue
+	}
+	if outputs, ok := value.(map[string]interface{}); ok {
+		for key, resources := range outputs {
+			items, _ := resources.([]interface{})
+			deployed.Resources[key] = []string{}
+			for _, item := range items {
+				if resource, ok := item.(string); ok {
+					deployed.Resources[key] = append(deployed.Resources[key], resource)
+				}
+			}
+		}
+	}
+
+	return deployed, nil
+}
+
+// HasNodeGroups returns whether a nodegroup of the cluster is deployed
+func (d DeployedResources) HasNodeGroups(clusterName string) bool {
+	for key := range d.NodeGroups {
+		if strings.HasPrefix(key, clusterName+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupClusterVersions reads the versions of the deployed clusters that were exported without one from EKS,
+// so that the upgrades of the clusters deployed before the version was exported are checked too
+func (d DeployedResources) LookupClusterVersions(ctx *pulumi.Context, clusterNames []string, clusterExists func(clusterName string) (bool, error)) error {
+	for _, clusterName := range clusterNames {
+		if !d.Clusters[clusterName] || d.ClusterVersions[clusterName] != "" {
+			continue
+		}
+		found, err := clusterExists(clusterName)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		log.Printf("Reading the version of cluster %s from EKS, it was exported without one", clusterName)
+		result, err := eks.LookupCluster(ctx, &eks.LookupClusterArgs{Name: clusterName})
+		if err != nil {
+			return err
+		}
+		d.ClusterVersions[clusterName] = result.Version
+	}
+	return nil
+}
+
+// newClusterLookup returns whether a cluster exists in AWS, the cluster names are listed on the first lookup
+func newClusterLookup(ctx *pulumi.Context) func(clusterName string) (bool, error) {
+	var clusterNames map[string]bool
+	return func(clusterName string) (bool, error) {
+		if clusterNames == nil {
+			log.Printf("Listing the clusters to check whether cluster %s exists", clusterName)
+			result, err := eks.GetClusters(ctx)
+			if err != nil {
+				return false, err
+			}
+			clusterNames = map[string]bool{}
+			for _, name := range result.Names {
+				clusterNames[name] = true
+			}
+		}
+		return clusterNames[clusterName], nil
+	}
+}
+
+// newNodeGroupLookup returns whether a nodegroup of the cluster exists in AWS, the nodegroups of the cluster are
+// listed on the first lookup. The nodegroups are created with their name as prefix, followed by 26 characters.
+func newNodeGroupLookup(ctx *pulumi.Context, clusterName string, clusterExists func(clusterName string) (bool, error)) func(nodeGroupName string) (bool, error) {
+	var nodeGroupNames []string
+	listed := false
+	return func(nodeGroupName string) (bool, error) {
+		if !listed {
+			found, err := clusterExists(clusterName)
+			if err != nil {
+				return false, err
+			}
+			if found {
+				log.Printf("Listing the nodegroups of cluster %s to check whether nodegroup %s exists", clusterName, nodeGroupName)
+				result, err := eks.GetNodeGroups(ctx, &eks.GetNodeGroupsArgs{ClusterName: clusterName})
+				if err != nil {
+					return false, err
+				}
+				nodeGroupNames = result.Names
+			}
+			listed = true
+		}
+		pattern := regexp.MustCompile("^" + regexp.QuoteMeta(nodeGroupName) + "[0-9a-f]{26}$")
+		for _, name := range nodeGroupNames {
+			if pattern.MatchString(name) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// ResourceTracker records the resources registered under every cluster and nodegroup, by "<cluster>" and
+// "<cluster>/<nodegroup>", they are exported so that the next update knows which resources are deployed under
+// them. The resources of the clusters and nodegroups left unchanged are registered with every input ignored and
+// held back until Close, so that none of them is created, and none deleted, unless Check found them deployed.
+type ResourceTracker struct {
+	resources map[string][]string
+	unchanged map[string]bool
+	gate      pulumi.ResourceArrayOutput
+	release   func(interface{})
+	refuse    func(error)
+}
+
+// NewResourceTracker returns a tracker with no resources recorded
+func NewResourceTracker() *ResourceTracker {
+	gate, release, refuse := pulumi.NewOutput()
+	return &ResourceTracker{
+		resources: map[string][]string{},
+		unchanged: map[string]bool{},
+		gate:      gate.ApplyT(func(interface{}) []pulumi.Resource { return nil }).(pulumi.ResourceArrayOutput),
+		release:   release,
+		refuse:    refuse,
+	}
+}
+
+// Track records the resource and its children under key, like "<type>::<name>", when unchanged they are left as
+// they are deployed
+func (tracker *ResourceTracker) Track(key string, unchanged bool) pulumi.ResourceOption {
+	if unchanged {
+		tracker.unchanged[key] = true
+	}
+	return pulumi.Transformations([]pulumi.ResourceTransformation{
+		func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+			tracker.resources[key] = append(tracker.resources[key], args.Type+"::"+args.Name)
+			// the result of the last transformation wins, those of the other resources leave the options alone
+			if !unchanged {
+				return nil
+			}
+			return &pulumi.ResourceTransformationResult{
+				Props: args.Props,
+				Opts:  append(args.Opts, pulumi.IgnoreChanges([]string{"*"}), pulumi.DependsOnInputs(tracker.gate)),
+			}
+		},
+	})
+}
+
+// Check compares the resources of the clusters and nodegroups left unchanged with those deployed, by the same
+// keys, and refuses the run when one of them would have resources created or deleted
+func (tracker *ResourceTracker) Check(deployed map[string][]string) error {
+	for _, key := range getSortedKeys(tracker.unchanged) {
+		kind := "cluster"
+		if strings.Contains(key, "/") {
+			kind = "nodegroup"
+		}
+		deployedResources, ok := deployed[key]
+		if !ok {
+			return fmt.Errorf("%s %s is left unchanged but its resources are missing from the outputs of the stack, run once without include-clusters, exclude-clusters and include-nodegroups to export them", kind, key)
+		}
+		if added := getMissingKeys(tracker.resources[key], deployedResources); len(added) > 0 {
+			return fmt.Errorf("%s %s is left unchanged but its config adds %s since the last update, they would be created although it is excluded", kind, key, strings.Join(added, ", "))
+		}
+		if removed := getMissingKeys(deployedResources, tracker.resources[key]); len(removed) > 0 {
+			return fmt.Errorf("%s %s is left unchanged but its config removes %s since the last update, they would be deleted although it is excluded", kind, key, strings.Join(removed, ", "))
+		}
+	}
+	return nil
+}
+
+// Close registers the resources held back, or fails them with err
+func (tracker *ResourceTracker) Close(err error) {
+	if err != nil {
+		tracker.refuse(err)
+		return
+	}
+	tracker.release(nil)
+}
+
+// Outputs returns the recorded resources by key, sorted
+func (tracker *ResourceTracker) Outputs() pulumi.StringArrayMap {
+	outputs := pulumi.StringArrayMap{}
+	for key, resources := range tracker.resources {
+		sorted := append([]string{}, resources...)
+		sort.Strings(sorted)
+		outputs[key] = pulumi.ToStringArray(sorted)
+	}
+	return outputs
+}
+
+// getMissingKeys returns the keys missing from others, sorted
+func getMissingKeys(keys, others []string) []string {
+	found := map[string]bool{}
+	for _, key := range others {
+		found[key] = true
+	}
+	var missing []string
+	for _, key := range keys {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// getSortedKeys returns the keys of the map, sorted
+func getSortedKeys(items map[string]bool) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,10 @@
+package utils
+
+// AccessPolicies maps the EKS access policy names accepted in the access section to their ARNs
+var AccessPolicies = map[string]string{
+	"AmazonEKSClusterAdminPolicy": "arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy",
+	"AmazonEKSAdminPolicy":        "arn:aws:eks::aws:cluster-access-policy/AmazonEKSAdminPolicy",
+	"AmazonEKSAdminViewPolicy":    "arn:aws:eks::aws:cluster-access-policy/AmazonEKSAdminViewPolicy",
+	"AmazonEKSEditPolicy":         "arn:aws:eks::aws:cluster-access-policy/AmazonEKSEditPolicy",
+	"AmazonEKSViewPolicy":         "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy",
+}
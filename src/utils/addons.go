@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v2"
+)
+
+// LatestCompatibleAddonVersion resolves to the most recent add-on version compatible with the cluster's
+// Kubernetes version on every run, so the add-on follows the cluster when it is upgraded
+const LatestCompatibleAddonVersion = "latest-compatible"
+
+// AddonDefinition describes an EKS managed add-on that can be declared in the cluster config
+type AddonDefinition struct {
+	// ServiceAccount is the service account used by the add-on, with the policies it needs,
+	// nil when the add-on does not call AWS APIs
+	ServiceAccount *ServiceAccountRole
+	// RequiresNodes is true when the add-on runs a deployment that only becomes healthy once nodes are available
+	RequiresNodes bool
+}
+
+// KnownAddons lists the add-on names accepted in the addons section of the cluster config
+var KnownAddons = map[string]AddonDefinition{
+	"vpc-cni": {
+		ServiceAccount: &ServiceAccountRole{
+			Namespace:          "kube-system",
+			ServiceAccountName: "aws-node",
+			ManagedPolicyArns:  []string{"arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy"},
+		},
+	},
+	"kube-proxy": {},
+	"coredns": {
+		RequiresNodes: true,
+	},
+	"aws-ebs-csi-driver": {
+		ServiceAccount: &ServiceAccountRole{
+			Namespace:          "kube-system",
+			ServiceAccountName: "ebs-csi-controller-sa",
+			ManagedPolicyArns:  []string{"arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy"},
+		},
+		RequiresNodes: true,
+	},
+	"aws-efs-csi-driver": {
+		ServiceAccount: &ServiceAccountRole{
+			Namespace:          "kube-system",
+			ServiceAccountName: "efs-csi-controller-sa",
+			ManagedPolicyArns:  []string{"arn:aws:iam::aws:policy/service-role/AmazonEFSCSIDriverPolicy"},
+		},
+		RequiresNodes: true,
+	},
+	"snapshot-controller": {
+		RequiresNodes: true,
+	},
+	"eks-pod-identity-agent": {},
+	"adot": {
+		RequiresNodes: true,
+	},
+}
+
+// ConvertConfigurationValuesToJSON converts add-on configuration values written as YAML or JSON into JSON
+func ConvertConfigurationValuesToJSON(configurationValues string) (string, error) {
+	trimmed := strings.TrimSpace(configurationValues)
+	if trimmed == "" {
+		return "", nil
+	}
+
+	// JSON is accepted as is, but has to be well formed
+	if strings.HasPrefix(trimmed, "{") {
+		if !json.Valid([]byte(trimmed)) {
+			return "", errors.New("configuration values are not valid JSON")
+		}
+		return trimmed, nil
+	}
+
+	var values map[string]interface{}
+	err := yaml.Unmarshal([]byte(trimmed), &values)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(convertYamlToJSONCompatible(values))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// yaml.v2 decodes nested mappings as map[interface{}]interface{}, which encoding/json can not marshal
+func convertYamlToJSONCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[toString(key)] = convertYamlToJSONCompatible(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = convertYamlToJSONCompatible(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = convertYamlToJSONCompatible(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func toString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// custom validation functions for addon name field
+func validateAddonName(fl validator.FieldLevel) bool {
+	_, ok := KnownAddons[fl.Field().String()]
+	return ok
+}
+
+// custom validation functions for addon configuration values field
+func validateConfigurationValues(fl validator.FieldLevel) bool {
+	_, err := ConvertConfigurationValuesToJSON(fl.Field().String())
+	return err == nil
+}
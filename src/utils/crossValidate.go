@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// qualifiedNamePattern is the name part of a Kubernetes label or taint key and the syntax of a label value
+	qualifiedNamePattern = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+	// dnsSubdomainPattern is the optional prefix of a Kubernetes label or taint key
+	dnsSubdomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// reservedLabelDomains are the label prefixes owned by Kubernetes and EKS, along with their subdomains
+var reservedLabelDomains = []string{"kubernetes.io", "k8s.io", "eks.amazonaws.com"}
+
+// ValidateClusterNodeGroups checks the nodegroups of a cluster against the cluster, the Kubernetes syntax
+// of their labels and taints and their instance types, once both have been read and validated on their own.
+// The ValidationErrors returned may only hold warnings.
+func ValidateClusterNodeGroups(clusterConfig ClusterConfig, nodeGroupConfigs []NodeGroupConfig) error {
+	var errs ValidationErrors
+	clusterSubnets := map[string]bool{}
+	for _, subnetId := range clusterConfig.SubnetIds {
+		clusterSubnets[subnetId] = true
+	}
+
+	for _, nodeGroupConfig := range nodeGroupConfigs {
+		newError := func(rule, message string, keyPath ...yamlKey) {
+			validationError := ValidationError{Path: formatYamlKeyPath(keyPath), Rule: rule, Message: message}
+			if document := nodeGroupConfig.document; document != nil {
+				node := findYamlNode(document.Root, keyPath)
+				validationError.File, validationError.Line, validationError.Column = document.getFile(node), node.Line, node.Column
+			}
+			errs = append(errs, validationError)
+		}
+		newWarning := func(rule, message string, keyPath ...yamlKey) {
+			newError(rule, message, keyPath...)
+			errs[len(errs)-1].Warning = true
+		}
+
+		// nodes in a subnet the control plane does not use are an explicit choice
+		if !nodeGroupConfig.NetworkConfiguration.AllowSubnetsOutsideCluster {
+			for i, subnetId := range nodeGroupConfig.NetworkConfiguration.SubnetIds {
+				if !clusterSubnets[subnetId] {
+					newError("clustersubnet", fmt.Sprintf("subnet %s is not one of the subnetIds of cluster %s, set allowSubnetsOutsideCluster to use it anyway", subnetId, clusterConfig.Name),
+						yamlKey{Key: "networkConfiguration"}, yamlKey{Key: "subnetIds"}, yamlKey{Index: i, IsIndex: true})
+				}
+			}
+		}
+
+		for _, key := range getSortedKeys(nodeGroupConfig.KubernetesLabels) {
+			keyPath := []yamlKey{{Key: "kubernetesLabels"}, {Key: key}}
+			if message := getQualifiedKeyError(key); message != "" {
+				newError("labelkey", message, keyPath...)
+			} else if domain := getReservedLabelDomain(key); domain != "" {
+				newError("reservedlabel", fmt.Sprintf("label %q uses the prefix of %s, which is reserved for Kubernetes and EKS", key, domain), keyPath...)
+			}
+			if message := getLabelValueError(nodeGroupConfig.KubernetesLabels[key]); message != "" {
+				newError("labelvalue", message, keyPath...)
+			}
+		}
+
+		taintIndexes := map[string]int{}
+		for i, taint := range nodeGroupConfig.KubernetesTaints {
+			keyPath := []yamlKey{{Key: "kubernetesTaints"}, {Index: i, IsIndex: true}}
+			if message := getQualifiedKeyError(taint.Key); message != "" {
+				newError("taintkey", message, append(keyPath, yamlKey{Key: "key"})...)
+			}
+			if message := getLabelValueError(taint.Value); message != "" {
+				newError("taintvalue", message, append(keyPath, yamlKey{Key: "value"})...)
+			}
+			// a node holds one taint per key and effect
+			if first, ok := taintIndexes[taint.Key+":"+taint.Effect]; ok {
+				newError("duplicate_taint", fmt.Sprintf("taint %s with effect %s is already defined by kubernetesTaints[%d]", taint.Key, taint.Effect, first), keyPath...)
+				continue
+			}
+			taintIndexes[taint.Key+":"+taint.Effect] = i
+		}
+
+		// the nodegroups follow the cluster version unless they pin an older one to be upgraded later
+		computeConfig := nodeGroupConfig.ComputeConfiguration
+		if computeConfig.AmiType != "CUSTOM" {
+			clusterMinor, clusterOk := parseMinorVersion(clusterConfig.Version)
+			minor, ok := parseMinorVersion(computeConfig.Version)
+			versionPath := []yamlKey{{Key: "computeConfiguration"}, {Key: "version"}}
+			switch {
+			case !clusterOk:
+			case ok && minor > clusterMinor:
+				newError("nodegroup_version", fmt.Sprintf("version %s is newer than the version %s of cluster %s, upgrade the cluster first", computeConfig.Version, clusterConfig.Version, clusterConfig.Name), versionPath...)
+			case ok && minor < clusterMinor-maxNodeVersionSkew:
+				newError("nodegroup_version", fmt.Sprintf("version %s is more than %d minor versions older than the version %s of cluster %s", computeConfig.Version, maxNodeVersionSkew, clusterConfig.Version, clusterConfig.Name), versionPath...)
+			case computeConfig.Version == "" && computeConfig.ReleaseVersion != "" && !isReleaseOfVersion(computeConfig.ReleaseVersion, clusterConfig.Version):
+				newError("releaseofversion", fmt.Sprintf("release %s is not a release of the version %s of cluster %s, set version to pin the nodegroup to another version", computeConfig.ReleaseVersion, clusterConfig.Version, clusterConfig.Name),
+					yamlKey{Key: "computeConfiguration"}, yamlKey{Key: "releaseVersion"})
+			}
+		}
+
+		for _, problem := range getInstanceTypeProblems(nodeGroupConfig.ComputeConfiguration) {
+			keyPath := []yamlKey{{Key: "computeConfiguration"}, {Key: "instanceTypes"}, {Index: problem.index, IsIndex: true}}
+			if problem.warning {
+				newWarning(problem.rule, problem.message, keyPath...)
+			} else {
+				newError(problem.rule, problem.message, keyPath...)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// getQualifiedKeyError describes why key is not a Kubernetes qualified name like example.com/name,
+// or returns an empty string when it is
+func getQualifiedKeyError(key string) string {
+	name := key
+	if prefix, suffix, ok := strings.Cut(key, "/"); ok {
+		if prefix == "" || len(prefix) > 253 || !dnsSubdomainPattern.MatchString(prefix) {
+			return fmt.Sprintf("key %q must have a prefix that is a lowercase DNS subdomain of at most 253 characters", key)
+		}
+		name = suffix
+	}
+	if len(name) > 63 || !qualifiedNamePattern.MatchString(name) {
+		return fmt.Sprintf("key %q must have a name of at most 63 letters, digits, '-', '_' or '.', starting and ending with a letter or digit", key)
+	}
+	return ""
+}
+
+// getLabelValueError describes why value is not a Kubernetes label value, or returns an empty string when it is
+func getLabelValueError(value string) string {
+	if value != "" && (len(value) > 63 || !qualifiedNamePattern.MatchString(value)) {
+		return fmt.Sprintf("value %q must be at most 63 letters, digits, '-', '_' or '.', starting and ending with a letter or digit", value)
+	}
+	return ""
+}
+
+// getReservedLabelDomain returns the reserved domain the prefix of key belongs to, or an empty string
+func getReservedLabelDomain(key string) string {
+	prefix, _, ok := strings.Cut(key, "/")
+	if !ok {
+		return ""
+	}
+	for _, domain := range reservedLabelDomains {
+		if prefix == domain || strings.HasSuffix(prefix, "."+domain) {
+			return domain
+		}
+	}
+	return ""
+}
+
+// instanceTypeProblem is a problem of computeConfiguration.instanceTypes[index]
+type instanceTypeProblem struct {
+	index   int
+	rule    string
+	message string
+	warning bool
+}
+
+// getInstanceTypeProblems checks the instance types against the instance type catalog and the AMI type,
+// the types missing from the catalog are only warned about as it may be older than the types
+func getInstanceTypeProblems(computeConfig ComputeConfig) []instanceTypeProblem {
+	var problems []instanceTypeProblem
+	amiArchitecture := getAmiArchitecture(computeConfig.AmiType)
+	amiAccelerators := getAmiAccelerators(computeConfig.AmiType)
+	firstType, firstArchitecture := "", ""
+	for i, instanceType := range computeConfig.InstanceTypes {
+		family, ok := LookupInstanceFamily(instanceType)
+		if !ok || !family.HasSize(instanceType) {
+			problems = append(problems, instanceTypeProblem{i, "instancetype_catalog", fmt.Sprintf("instance type %s is not in the instance type catalog, its architecture and accelerators are not checked", instanceType), true})
+			if !ok {
+				continue
+			}
+		}
+		if !family.SupportedInEks {
+			problems = append(problems, instanceTypeProblem{i, "instancetype_eks", fmt.Sprintf("instance type %s is not supported by EKS managed nodegroups", instanceType), false})
+			continue
+		}
+
+		// the AMI fixes the architecture of every instance type, a custom AMI only requires that they agree
+		switch {
+		case amiArchitecture != "" && family.Architecture != amiArchitecture:
+			problems = append(problems, instanceTypeProblem{i, "amitype_arch", fmt.Sprintf("instance type %s is %s while amiType %s is %s", instanceType, family.Architecture, computeConfig.AmiType, amiArchitecture), false})
+		case amiArchitecture == "" && firstArchitecture != "" && family.Architecture != firstArchitecture:
+			problems = append(problems, instanceTypeProblem{i, "instancetype_arch", fmt.Sprintf("instance type %s is %s while %s is %s, the instance types of a nodegroup must share an architecture", instanceType, family.Architecture, firstType, firstArchitecture), false})
+		case firstArchitecture == "":
+			firstType, firstArchitecture = instanceType, family.Architecture
+		}
+
+		if len(amiAccelerators) > 0 && !slices.Contains(amiAccelerators, family.Accelerator) {
+			problems = append(problems, instanceTypeProblem{i, "amitype_gpu", fmt.Sprintf("amiType %s needs instance types with %s accelerators, %s has none", computeConfig.AmiType, strings.Join(amiAccelerators, " or "), instanceType), false})
+		}
+		if !family.CurrentGeneration {
+			problems = append(problems, instanceTypeProblem{i, "instancetype_generation", fmt.Sprintf("instance type %s is of a previous generation, a current generation family is usually cheaper and faster", instanceType), true})
+		}
+	}
+	return problems
+}
+
+// getAmiArchitecture returns the architecture of the instances an AMI type runs on, or an empty string for CUSTOM
+func getAmiArchitecture(amiType string) string {
+	switch {
+	case amiType == "CUSTOM":
+		return ""
+	case strings.Contains(amiType, "ARM_64"):
+		return "arm64"
+	default:
+		return "x86_64"
+	}
+}
+
+// getAmiAccelerators returns the accelerators one of which an AMI type needs, the AL2 GPU AMI also
+// ships the Neuron driver of the Inferentia and Trainium instances
+func getAmiAccelerators(amiType string) []string {
+	switch {
+	case amiType == "AL2_x86_64_GPU":
+		return []string{AcceleratorNvidia, AcceleratorNeuron}
+	case strings.HasSuffix(amiType, "_NVIDIA"):
+		return []string{AcceleratorNvidia}
+	case strings.HasSuffix(amiType, "_NEURON"):
+		return []string{AcceleratorNeuron}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	// environmentDefaultsFile is read from clusters/<env>, next to the cluster directories
+	environmentDefaultsFile = "defaults.yaml"
+	// nodeGroupDefaultsFile is read from clusters/<env>/<cluster>/nodegroups and is not a nodegroup itself
+	nodeGroupDefaultsFile = "_defaults.yaml"
+	// resetTag clears an inherited value with `key: !reset`, or replaces an inherited mapping
+	// instead of merging into it with `key: !reset {...}`
+	resetTag = "!reset"
+)
+
+// DefaultsConfig is clusters/<env>/defaults.yaml, the settings inherited by every cluster of the environment
+type DefaultsConfig struct {
+	NodeGroups NodeGroupConfig `yaml:"nodegroups"` // deep merged under every nodegroup, only the keys that are set
+}
+
+// readNodeGroupDefaults returns the environment and cluster defaults merged under the nodegroups of
+// nodeGroupDir, most general first
+func readNodeGroupDefaults(nodeGroupDir string) ([]*configDocument, ValidationErrors) {
+	var defaults []*configDocument
+	var errs ValidationErrors
+
+	environmentDefaultsPath := filepath.Join(getEnvironmentDir(nodeGroupDir), environmentDefaultsFile)
+	if _, err := os.Stat(environmentDefaultsPath); err == nil {
+		log.Printf("Reading environment defaults file: %s", environmentDefaultsPath)
+		document, documentErrs := readConfigDocument(environmentDefaultsPath)
+		errs = append(errs, documentErrs...)
+		if document != nil {
+			errs = append(errs, checkYamlKeys(document, reflect.TypeOf(DefaultsConfig{}))...)
+			if section := getMappingValue(document.Root, "nodegroups"); section != nil {
+				defaults = append(defaults, &configDocument{Path: environmentDefaultsPath, Root: section})
+			}
+		}
+	}
+
+	nodeGroupDefaultsPath := filepath.Join(nodeGroupDir, nodeGroupDefaultsFile)
+	if _, err := os.Stat(nodeGroupDefaultsPath); err == nil {
+		log.Printf("Reading nodegroup defaults file: %s", nodeGroupDefaultsPath)
+		document, documentErrs := readConfigDocument(nodeGroupDefaultsPath)
+		errs = append(errs, documentErrs...)
+		if document != nil {
+			errs = append(errs, checkYamlKeys(document, reflect.TypeOf(NodeGroupConfig{}))...)
+			defaults = append(defaults, document)
+		}
+	}
+
+	return defaults, errs
+}
+
+// getEnvironmentDir returns clusters/<env> of clusters/<env>/<cluster>/nodegroups
+func getEnvironmentDir(nodeGroupDir string) string {
+	return filepath.Dir(filepath.Dir(nodeGroupDir))
+}
+
+func getMappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mergeConfigDocuments deep merges the documents in order, each overriding the ones before it:
+// mappings merge key by key, lists and scalars replace, and !reset clears or replaces a value
+func mergeConfigDocuments(documents ...*configDocument) *configDocument {
+	merged := &configDocument{Path: documents[len(documents)-1].Path, Sources: map[*yamlv3.Node]string{}}
+	var root *yamlv3.Node
+	for _, document := range documents {
+		recordSources(document, document.Root, merged.Sources)
+		root = mergeYamlNodes(root, document.Root, merged.Sources)
+	}
+	if root == nil {
+		root = &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map", Line: 1, Column: 1}
+		merged.Sources[root] = merged.Path
+	}
+	merged.Root = root
+	return merged
+}
+
+func recordSources(document *configDocument, node *yamlv3.Node, sources map[*yamlv3.Node]string) {
+	sources[node] = document.getFile(node)
+	for _, child := range node.Content {
+		recordSources(document, child, sources)
+	}
+}
+
+// mergeYamlNodes returns override merged over base, nil when override clears the value
+func mergeYamlNodes(base, override *yamlv3.Node, sources map[*yamlv3.Node]string) *yamlv3.Node {
+	if override.Tag == resetTag {
+		if override.Kind == yamlv3.ScalarNode && (override.Value == "" || override.Value == "~" || override.Value == "null") {
+			return nil
+		}
+		// the tagged value replaces the inherited one, with the tag resolved again when decoding
+		replacement := *override
+		replacement.Tag = ""
+		sources[&replacement] = sources[override]
+		base = nil
+		override = &replacement
+	}
+	if override.Kind != yamlv3.MappingNode || (base != nil && base.Kind != yamlv3.MappingNode) {
+		return override
+	}
+
+	merged := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: override.Tag, Style: override.Style, Line: override.Line, Column: override.Column}
+	sources[merged] = sources[override]
+
+	// the keys keep the order of the most specific file, followed by the inherited keys it does not set
+	lastOverrideIndexes := map[string]int{}
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		lastOverrideIndexes[override.Content[i].Value] = i
+	}
+	baseIndexes := map[string]int{}
+	if base != nil {
+		for i := 0; i+1 < len(base.Content); i += 2 {
+			baseIndexes[base.Content[i].Value] = i
+		}
+	}
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key := override.Content[i].Value
+		if lastOverrideIndexes[key] != i {
+			continue
+		}
+		var baseValue *yamlv3.Node
+		if j, ok := baseIndexes[key]; ok {
+			baseValue = base.Content[j+1]
+		}
+		if value := mergeYamlNodes(baseValue, override.Content[i+1], sources); value != nil {
+			merged.Content = append(merged.Content, override.Content[i], value)
+		}
+	}
+	if base != nil {
+		for i := 0; i+1 < len(base.Content); i += 2 {
+			key := base.Content[i].Value
+			if _, ok := lastOverrideIndexes[key]; ok || baseIndexes[key] != i {
+				continue
+			}
+			merged.Content = append(merged.Content, base.Content[i], base.Content[i+1])
+		}
+	}
+	return merged
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// clusterConfigFile is read from every clusters/<env>/<cluster> directory
+const clusterConfigFile = "config.yaml"
+
+// isConfigFileName reports whether name is a config file of a nodegroup or fargate profile directory,
+// hidden files like editor swap files and `_` prefixed files like _defaults.yaml are not
+func isConfigFileName(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return false
+	}
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// listConfigFiles returns the config files directly inside dir sorted by name, subdirectories are
+// not searched and a missing dir has none
+func listConfigFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isConfigFileName(entry.Name()) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// listClusterDirs returns the directories directly inside rootDir that hold a config.yaml sorted by
+// name, skipping hidden and `_` prefixed directories
+func listClusterDirs(rootDir string) ([]string, error) {
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterDirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || strings.HasPrefix(entry.Name(), "_") {
+			continue
+		}
+		clusterDir := filepath.Join(rootDir, entry.Name())
+		if info, err := os.Stat(filepath.Join(clusterDir, clusterConfigFile)); err == nil && info.Mode().IsRegular() {
+			clusterDirs = append(clusterDirs, clusterDir)
+		}
+	}
+	return clusterDirs, nil
+}
+
+// configNames records the file defining each name of a kind of config, as the names identify the
+// resources and two files with the same name would silently replace one another
+type configNames struct {
+	kind  string
+	files map[string]string
+}
+
+func newConfigNames(kind string) *configNames {
+	return &configNames{kind: kind, files: map[string]string{}}
+}
+
+// add records the name of the config decoded from document, with an error naming both files
+// when another file already defines it
+func (n *configNames) add(name string, document *configDocument) ValidationErrors {
+	if name == "" || document == nil {
+		return nil
+	}
+	firstFile, ok := n.files[name]
+	if !ok {
+		n.files[name] = document.Path
+		return nil
+	}
+
+	node := findYamlNode(document.Root, []yamlKey{{Key: "name"}})
+	return ValidationErrors{{
+		File:    document.getFile(node),
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    "name",
+		Rule:    "duplicate_name",
+		Message: fmt.Sprintf("%s name %q is already defined in %s", n.kind, name, firstFile),
+	}}
+}
+
+// checkClusterDir reports a cluster whose name differs from its directory, which would otherwise only be
+// noticed by the resources of the cluster being named after the other one
+func checkClusterDir(name, clusterDir string, document *configDocument) ValidationErrors {
+	if name == "" || document == nil || name == filepath.Base(clusterDir) {
+		return nil
+	}
+
+	node := findYamlNode(document.Root, []yamlKey{{Key: "name"}})
+	return ValidationErrors{{
+		File:    document.getFile(node),
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    "name",
+		Rule:    "directory_name",
+		Message: fmt.Sprintf("cluster name %q must match its directory %q", name, filepath.Base(clusterDir)),
+	}}
+}
+
+// GetClusterNames returns the names of the clusters sorted, the order in which they are created
+func GetClusterNames(clusterConfigs map[string]ClusterConfig) []string {
+	return getSortedKeys(clusterConfigs)
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+)
+
+// instanceCatalogJSON is generated by src/cmd/eks-iaac-catalog from the output of aws ec2 describe-instance-types,
+// or from the instances.json of ec2instances.info which is collected from it
+//
+//go:embed instance-types.json
+var instanceCatalogJSON []byte
+
+// InstanceCatalog is the offline catalog of the EC2 instance families the nodegroups are checked against
+type InstanceCatalog struct {
+	Families map[string]InstanceFamily `json:"families"`
+}
+
+// InstanceFamily describes the instance types sharing the part of their name before the dot, like m5
+type InstanceFamily struct {
+	Architecture      string                  `json:"architecture"`          // x86_64 or arm64, the mac families have their own
+	Accelerator       string                  `json:"accelerator,omitempty"` // nvidia or neuron, empty without one
+	CurrentGeneration bool                    `json:"currentGeneration"`
+	SupportedInEks    bool                    `json:"supportedInEks"`
+	Sizes             map[string]InstanceSize `json:"sizes"` // keyed by the part of the name after the dot, like large
+}
+
+type InstanceSize struct {
+	Vcpus     int `json:"vcpus"`
+	MemoryMiB int `json:"memoryMiB"`
+}
+
+// Accelerators of the instance families
+const (
+	AcceleratorNvidia = "nvidia"
+	AcceleratorNeuron = "neuron"
+)
+
+var instanceCatalog = mustParseInstanceCatalog(instanceCatalogJSON)
+
+func mustParseInstanceCatalog(data []byte) InstanceCatalog {
+	var catalog InstanceCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		panic("invalid embedded instance type catalog: " + err.Error())
+	}
+	return catalog
+}
+
+// GetInstanceCatalog returns the embedded instance type catalog
+func GetInstanceCatalog() InstanceCatalog {
+	return instanceCatalog
+}
+
+// LookupInstanceFamily returns the catalog family of an instance type like m5.large, and whether it is in the catalog
+func LookupInstanceFamily(instanceType string) (InstanceFamily, bool) {
+	familyName, _, _ := strings.Cut(instanceType, ".")
+	family, ok := instanceCatalog.Families[familyName]
+	return family, ok
+}
+
+// HasSize returns whether the family has the size of an instance type like m5.large
+func (f InstanceFamily) HasSize(instanceType string) bool {
+	_, size, _ := strings.Cut(instanceType, ".")
+	_, ok := f.Sizes[size]
+	return ok
+}
@@ -1,70 +1,174 @@
 package utils
 
 import (
+	"fmt"
 	"log"
-	"os"
 	"path/filepath"
-
-	"gopkg.in/yaml.v2"
 )
 
 type ClusterConfig struct {
-    Name              string `yaml:"name" validate:"required"`
-    Version           string `yaml:"version" validate:"required"`
+    Name              string `yaml:"name" validate:"required,max=100,clustername"` // must match the directory of the config.yaml
+    Dir               string `yaml:"-"` // the directory the config.yaml was read from, set by ReadClusterConfigs
+    Version           string `yaml:"version" validate:"required,kubernetesversion"` // a listed EKS version like 1.32, upgraded one minor version at a time
     RoleArn           string `yaml:"roleArn" validate:"omitempty,rolearn"` 	// roleArn field is optional 
-	ServiceIpv4Cidr   string `yaml:"serviceIpv4Cidr" validate:"required,cidrv4"`
-    PublicAccessCidrs []string `yaml:"publicAccessCidrs" validate:"required,dive,cidrv4"`
+	ServiceIpv4Cidr   string `yaml:"serviceIpv4Cidr" validate:"required,cidrv4"` // private, between /12 and /24, outside vpcCidrs and podCidrs
+    VpcCidrs          []string `yaml:"vpcCidrs" validate:"omitempty,dive,cidrv4"` // CIDR blocks of the VPC, only checked against serviceIpv4Cidr
+    PodCidrs          []string `yaml:"podCidrs" validate:"omitempty,dive,cidrv4"` // secondary CIDR blocks of the pods with VPC CNI custom networking, only checked against serviceIpv4Cidr
+    PublicAccessCidrs []string `yaml:"publicAccessCidrs" validate:"omitempty,dive,cidrv4"` // required while the public endpoint is enabled
+    EndpointPrivateAccess *bool `yaml:"endpointPrivateAccess"` // defaults to false
+    EndpointPublicAccess  *bool `yaml:"endpointPublicAccess"` // defaults to true
+    EnabledLogTypes   []string `yaml:"enabledLogTypes" validate:"omitempty,unique,dive,oneof=api audit authenticator controllerManager scheduler"` // defaults to all five, [] disables logging
+    LogRetentionInDays int `yaml:"logRetentionInDays" validate:"omitempty,oneof=1 3 5 7 14 30 60 90 120 150 180 365 400 545 731 1096 1827 2192 2557 2922 3288 3653"` // creates the cluster log group with this retention
+    LogKmsKeyArn      string `yaml:"logKmsKeyArn" validate:"omitempty,kmskeyarn"` // encrypts the cluster log group
     SecurityGroupIds  []string `yaml:"securityGroupIds" validate:"required,dive,securitygroupid"`
     SubnetIds         []string `yaml:"subnetIds" validate:"required,dive,subnetid"`
     Tags              map[string]string `yaml:"tags" validate:"required,dive"`
+    Oidc              *OidcConfig `yaml:"oidc" validate:"required_with=ServiceAccountRoles,omitempty"` // oidc field is optional, an empty block enables the provider
+    ServiceAccountRoles []ServiceAccountRole `yaml:"serviceAccountRoles" validate:"omitempty,dive"`
+    Addons            []AddonConfig `yaml:"addons" validate:"omitempty,unique=Name,dive"`
+    Access            *AccessConfig `yaml:"access" validate:"omitempty"` // access field is optional
+    Encryption        *EncryptionConfig `yaml:"encryption" validate:"omitempty"` // encryption field is optional
+
+    document *configDocument // the YAML the config was decoded from, to locate the errors of its version
 }
 
-func ReadClusterConfigs(rootDir string) ([]ClusterConfig, error) {
-	var clusterConfigs []ClusterConfig
+type EncryptionConfig struct {
+    KmsKeyArn            string `yaml:"kmsKeyArn" validate:"omitempty,kmskeyarn"` // a dedicated key with rotation is created when empty
+    DeletionWindowInDays int    `yaml:"deletionWindowInDays" validate:"omitempty,min=7,max=30,excluded_with=KmsKeyArn"` // defaults to 30, only for the created key
+}
 
-	// Walk through the root directory and its subdirectories
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+type OidcConfig struct {
+    ClientIds   []string `yaml:"clientIds" validate:"omitempty,dive,required"` // defaults to sts.amazonaws.com
+    Thumbprints []string `yaml:"thumbprints" validate:"omitempty,dive,thumbprint"` // computed from the issuer certificate when empty
+}
 
-		// If the current file is a config.yaml file, read it
-		if info.IsDir() || filepath.Base(path) != "config.yaml" {
-			return nil
-		}
+type ServiceAccountRole struct {
+    RoleName           string   `yaml:"roleName" validate:"omitempty,max=64"` // defaults to <cluster>-<namespace>-<serviceAccountName>-irsa-role
+    Namespace          string   `yaml:"namespace" validate:"required"`
+    ServiceAccountName string   `yaml:"serviceAccountName" validate:"required"`
+    ManagedPolicyArns  []string `yaml:"managedPolicyArns" validate:"omitempty,dive,policyarn"`
+    InlinePolicy       string   `yaml:"inlinePolicy" validate:"omitempty,json"`
+}
 
-		log.Printf("Reading config file: %s", path)
+type AddonConfig struct {
+    Name                     string `yaml:"name" validate:"required,addonname"`
+    Version                  string `yaml:"version" validate:"omitempty,addonversion"` // "latest-compatible" follows the cluster version, empty keeps the installed version so the cluster version can not change
+    ResolveConflicts         string `yaml:"resolveConflicts" validate:"omitempty,oneof=NONE OVERWRITE"`
+    ServiceAccountRoleArn    string `yaml:"serviceAccountRoleArn" validate:"omitempty,rolearn,excluded_with=CreateServiceAccountRole"`
+    CreateServiceAccountRole bool   `yaml:"createServiceAccountRole"` // creates an IRSA role for the add-on service account, requires oidc
+    ConfigurationValues      string `yaml:"configurationValues" validate:"omitempty,configurationvalues"` // YAML or JSON
+}
 
-		// Read the config.yaml file
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
+type AccessConfig struct {
+    AuthenticationMode string `yaml:"authenticationMode" validate:"omitempty,oneof=API API_AND_CONFIG_MAP CONFIG_MAP"` // defaults to API_AND_CONFIG_MAP
+    Entries            []AccessEntryConfig `yaml:"entries" validate:"omitempty,unique=PrincipalArn,dive"`
+}
 
-		// Unmarshal the YAML data into a ClusterConfig struct
-		var clusterConfig ClusterConfig
-		err = yaml.Unmarshal(data, &clusterConfig)
-		if err != nil {
-			return err
-		}
+type AccessEntryConfig struct {
+    PrincipalArn     string `yaml:"principalArn" validate:"required,principalarn"`
+    KubernetesGroups []string `yaml:"kubernetesGroups" validate:"omitempty,dive,required"`
+    Username         string `yaml:"username"`
+    Policies         []AccessPolicyConfig `yaml:"policies" validate:"omitempty,unique=Name,dive"`
+}
 
-		// Validate the ClusterConfig
-		err = ValidateConfigs(&clusterConfig)
-		if err != nil {
-			return err
-		}
+type AccessPolicyConfig struct {
+    Name       string `yaml:"name" validate:"required,accesspolicy"` // e.g. AmazonEKSClusterAdminPolicy, AmazonEKSViewPolicy
+    Scope      string `yaml:"scope" validate:"required,oneof=cluster namespace"`
+    Namespaces []string `yaml:"namespaces" validate:"required_if=Scope namespace,excluded_if=Scope cluster,omitempty,dive,required"`
+}
 
-		// Add the ClusterConfig to the slice
-		clusterConfigs = append(clusterConfigs, clusterConfig)
+// ClusterLogTypes are the control plane log types EKS can send to CloudWatch
+var ClusterLogTypes = []string{"api", "audit", "authenticator", "controllerManager", "scheduler"}
 
-		log.Printf("Successfully read config file: %s", path)
+// GetEnabledLogTypes returns the configured log types, all of them when the field is not set
+func (clusterConfig ClusterConfig) GetEnabledLogTypes() []string {
+    if clusterConfig.EnabledLogTypes == nil {
+        return ClusterLogTypes
+    }
+    return clusterConfig.EnabledLogTypes
+}
+
+// HasClusterLogGroup returns whether the cluster log group is created up front, only while logging is enabled
+// with a retention or a KMS key, otherwise EKS creates the group itself when the cluster starts logging
+func (clusterConfig ClusterConfig) HasClusterLogGroup() bool {
+    return len(clusterConfig.GetEnabledLogTypes()) > 0 && (clusterConfig.LogRetentionInDays != 0 || clusterConfig.LogKmsKeyArn != "")
+}
+
+// GetClientIds returns the audiences of the OIDC provider, sts.amazonaws.com by default
+func (oidcConfig OidcConfig) GetClientIds() []string {
+    if len(oidcConfig.ClientIds) == 0 {
+        return []string{"sts.amazonaws.com"}
+    }
+    return oidcConfig.ClientIds
+}
 
-		return nil
-	})
+// MaxRoleNameLength is the longest IAM role name
+const MaxRoleNameLength = 64
 
+// GetRoleName returns the configured role name or "<cluster>-<namespace>-<serviceAccountName>-irsa-role"
+func (serviceAccountRole ServiceAccountRole) GetRoleName(clusterName string) string {
+    if serviceAccountRole.RoleName != "" {
+        return serviceAccountRole.RoleName
+    }
+    return fmt.Sprintf("%s-%s-%s-irsa-role", clusterName, serviceAccountRole.Namespace, serviceAccountRole.ServiceAccountName)
+}
+
+// GetAddonRoleName returns the name of the IRSA role created for an add-on, "<cluster>-<addon>-irsa-role"
+func GetAddonRoleName(clusterName, addonName string) string {
+    return fmt.Sprintf("%s-%s-irsa-role", clusterName, addonName)
+}
+
+// IsEndpointPublicAccess returns whether the API server endpoint is reachable from the internet, true by default
+func (clusterConfig ClusterConfig) IsEndpointPublicAccess() bool {
+    return clusterConfig.EndpointPublicAccess == nil || *clusterConfig.EndpointPublicAccess
+}
+
+// IsEndpointPrivateAccess returns whether the API server endpoint is reachable from the VPC, false by default
+func (clusterConfig ClusterConfig) IsEndpointPrivateAccess() bool {
+    return clusterConfig.EndpointPrivateAccess != nil && *clusterConfig.EndpointPrivateAccess
+}
+
+// ReadClusterConfigs reads the config.yaml of every cluster directory of rootDir, keyed by cluster name,
+// the valid configs are returned along with the ValidationErrors of the invalid ones
+func ReadClusterConfigs(rootDir string, options TreeOptions) (map[string]ClusterConfig, error) {
+	clusterConfigs := map[string]ClusterConfig{}
+	var validationErrors ValidationErrors
+
+	// Only the directories directly inside the root directory are clusters, in a stable order
+	clusterDirs, err := listClusterDirs(rootDir)
 	if err != nil {
 		return nil, err
 	}
 
+	clusterNames := newConfigNames("cluster")
+	for _, clusterDir := range clusterDirs {
+		path := filepath.Join(clusterDir, clusterConfigFile)
+
+		log.Printf("Reading config file: %s", path)
+
+		// Read, unmarshal and validate the config.yaml file, the other files are still checked if it is invalid
+		var clusterConfig ClusterConfig
+		document, errs := decodeConfigFile(path, &clusterConfig)
+		if options.CheckClusterDirNames {
+			errs = append(errs, checkClusterDir(clusterConfig.Name, clusterDir, document)...)
+		}
+		errs = append(errs, clusterNames.add(clusterConfig.Name, document)...)
+		if len(errs) > 0 {
+			validationErrors = append(validationErrors, errs...)
+			continue
+		}
+
+		// Add the ClusterConfig to the map, its nodegroups and fargate profiles are read relative to its directory
+		clusterConfig.Dir = clusterDir
+		clusterConfig.document = document
+		clusterConfigs[clusterConfig.Name] = clusterConfig
+
+		log.Printf("Successfully read config file: %s", path)
+	}
+
+	if len(validationErrors) > 0 {
+		return clusterConfigs, validationErrors
+	}
+
 	return clusterConfigs, nil
 }
@@ -0,0 +1,55 @@
+package utils
+
+import (
+    "log"
+)
+
+type FargateProfileConfig struct {
+    Name                string `yaml:"name" validate:"required"`
+    Selectors           []FargateSelector `yaml:"selectors" validate:"required,min=1,max=5,dive"`
+    SubnetIds           []string `yaml:"subnetIds" validate:"required,dive,subnetid"` // private subnets only
+    PodExecutionRoleArn string `yaml:"podExecutionRoleArn" validate:"omitempty,rolearn"` // podExecutionRoleArn field is optional
+    Tags                map[string]string `yaml:"tags" validate:"omitempty,dive"`
+}
+
+type FargateSelector struct {
+    Namespace string `yaml:"namespace" validate:"required"`
+    Labels    map[string]string `yaml:"labels" validate:"omitempty,dive"`
+}
+
+func ReadFargateProfileConfigs(fargateProfileDirInClusterDir string) ([]FargateProfileConfig, error) {
+    var fargateProfileConfigs []FargateProfileConfig
+    var validationErrors ValidationErrors
+
+    // Fargate profiles are optional, a cluster without the directory has none, and only the yaml
+    // files directly inside the directory are fargate profiles, in a stable order
+    paths, err := listConfigFiles(fargateProfileDirInClusterDir)
+    if err != nil {
+        return nil, err
+    }
+
+    fargateProfileNames := newConfigNames("fargate profile")
+    for _, path := range paths {
+        log.Printf("Reading fargate profile file: %s", path)
+
+        // Read, unmarshal and validate the fargate profile yaml file, the other files are still checked if it is invalid
+        var fargateProfile FargateProfileConfig
+        document, errs := decodeConfigFile(path, &fargateProfile)
+        errs = append(errs, fargateProfileNames.add(fargateProfile.Name, document)...)
+        if len(errs) > 0 {
+            validationErrors = append(validationErrors, errs...)
+            continue
+        }
+
+        // Add the FargateProfileConfig to the slice
+        fargateProfileConfigs = append(fargateProfileConfigs, fargateProfile)
+
+        log.Printf("Successfully read fargate profile file: %s", path)
+    }
+
+    if len(validationErrors) > 0 {
+        return nil, validationErrors
+    }
+
+    return fargateProfileConfigs, nil
+}
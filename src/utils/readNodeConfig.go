@@ -2,10 +2,8 @@ package utils
 
 import (
 	"log"
-	"os"
 	"path/filepath"
-
-	"gopkg.in/yaml.v2"
+	"strings"
 )
 
 type NodeGroupConfig struct {
@@ -17,6 +15,11 @@ type NodeGroupConfig struct {
     Tags                 map[string]string `yaml:"tags" validate:"required,dive"`
     KubernetesLabels     map[string]string `yaml:"kubernetesLabels" validate:"required,dive"`
     KubernetesTaints     []KubernetesTaint `yaml:"kubernetesTaints" validate:"omitempty,dive"`
+    LaunchTemplate       *LaunchTemplateConfig `yaml:"launchTemplate" validate:"omitempty"` // launchTemplate field is optional
+    Template             string `yaml:"template"` // nodegroup template of clusters/<env>/templates merged under this file
+    Parameters           map[string]string `yaml:"parameters" validate:"excluded_without=Template"` // values of the template parameters
+
+    document *configDocument // the merged YAML the config was decoded from, to locate the errors of ValidateClusterNodeGroups
 }
 
 type ScalingConfig struct {
@@ -27,21 +30,63 @@ type ScalingConfig struct {
 }
 
 type MaximumUnavailable struct {
-    Type  string `yaml:"type" validate:"required"`
+    Type  string `yaml:"type" validate:"required,maxunavailabletype"`
     Value int    `yaml:"value" validate:"required"`
 }
 
 type NetworkConfig struct {
     SubnetIds         []string `yaml:"subnetIds" validate:"required,dive,subnetid"`
-    Ec2KeyPair        string `yaml:"ec2KeyPair" validate:"required"`
+    Ec2KeyPair        string `yaml:"ec2KeyPair"` // required unless a launch template is used
     SecurityGroupIds  []string `yaml:"securityGroupIds" validate:"required,dive,securitygroupid"`
+    AllowSubnetsOutsideCluster bool `yaml:"allowSubnetsOutsideCluster"` // subnetIds must be subnets of the cluster unless set
 }
 
 type ComputeConfig struct {
-    AmiType        string   `yaml:"amiType" validate:"required"`
-    CapacityType   string   `yaml:"capacityType" validate:"required"`
+    AmiType        string   `yaml:"amiType" validate:"required,amitype"`
+    CapacityType   string   `yaml:"capacityType" validate:"required,capacitytype"`
     InstanceTypes  []string `yaml:"instanceTypes" validate:"required,dive,instancetype"`
-    DiskSize       int      `yaml:"diskSize" validate:"required,min=8"`
+    DiskSize       int      `yaml:"diskSize" validate:"omitempty,min=8"` // required unless a launch template is used
+    Version        string   `yaml:"version" validate:"omitempty,kubernetesversion"` // defaults to the cluster version, to hold the nodes back during an upgrade
+    ReleaseVersion string   `yaml:"releaseVersion" validate:"omitempty,releaseversion"` // AMI release like 1.29.3-20240514, the latest of the version by default
+    ForceUpdateVersion bool `yaml:"forceUpdateVersion"` // replace the nodes even when their pods can not be drained because of a pod disruption budget
+}
+
+// AmiTypes are the AMI types of EKS managed nodegroups
+var AmiTypes = []string{
+    "AL2_x86_64", "AL2_x86_64_GPU", "AL2_ARM_64",
+    "AL2023_x86_64_STANDARD", "AL2023_ARM_64_STANDARD", "AL2023_x86_64_NEURON", "AL2023_x86_64_NVIDIA",
+    "BOTTLEROCKET_x86_64", "BOTTLEROCKET_ARM_64", "BOTTLEROCKET_x86_64_NVIDIA", "BOTTLEROCKET_ARM_64_NVIDIA",
+    "WINDOWS_CORE_2019_x86_64", "WINDOWS_FULL_2019_x86_64", "WINDOWS_CORE_2022_x86_64", "WINDOWS_FULL_2022_x86_64",
+    "CUSTOM",
+}
+
+// CapacityTypes are the capacity types of EKS managed nodegroups
+var CapacityTypes = []string{"ON_DEMAND", "SPOT", "CAPACITY_BLOCK"}
+
+// MaximumUnavailableTypes are the ways maximumUnavailable limits the nodes updated at once
+var MaximumUnavailableTypes = []string{"number", "percentage"}
+
+// normalizeEnums rewrites the maximumUnavailable type, AMI type and capacity type written in another case to
+// the values EKS expects, and returns the rewritten values to warn about as other cases are deprecated
+func (c *NodeGroupConfig) normalizeEnums() []enumNormalization {
+    var normalizations []enumNormalization
+    for _, field := range []struct {
+        value   *string
+        values  []string
+        keyPath []yamlKey
+    }{
+        {&c.ScalingConfiguration.MaximumUnavailable.Type, MaximumUnavailableTypes, []yamlKey{{Key: "scalingConfiguration"}, {Key: "maximumUnavailable"}, {Key: "type"}}},
+        {&c.ComputeConfiguration.AmiType, AmiTypes, []yamlKey{{Key: "computeConfiguration"}, {Key: "amiType"}}},
+        {&c.ComputeConfiguration.CapacityType, CapacityTypes, []yamlKey{{Key: "computeConfiguration"}, {Key: "capacityType"}}},
+    } {
+        for _, value := range field.values {
+            if *field.value != value && strings.EqualFold(*field.value, value) {
+                normalizations = append(normalizations, enumNormalization{KeyPath: field.keyPath, Value: *field.value, Normalized: value})
+                *field.value = value
+            }
+        }
+    }
+    return normalizations
 }
 
 type KubernetesTaint struct {
@@ -50,52 +95,78 @@ type KubernetesTaint struct {
     Effect string `yaml:"effect" validate:"required,tainteffect"`
 }
 
+type LaunchTemplateConfig struct {
+    ImageId             string `yaml:"imageId" validate:"omitempty,amiid"` // custom AMI, requires amiType CUSTOM
+    KeyName             string `yaml:"keyName"`
+    MetadataOptions     MetadataOptions `yaml:"metadataOptions"`
+    BlockDeviceMappings []BlockDeviceMapping `yaml:"blockDeviceMappings" validate:"omitempty,unique=DeviceName,dive"`
+    UserData            string `yaml:"userData"` // shell script, or a nodeadm NodeConfig for AL2023, required to bootstrap a custom AMI
+}
+
+type MetadataOptions struct {
+    HttpTokens              string `yaml:"httpTokens" validate:"omitempty,oneof=required optional"` // defaults to required (IMDSv2)
+    HttpPutResponseHopLimit int    `yaml:"httpPutResponseHopLimit" validate:"omitempty,min=1,max=64"` // defaults to 2 so pods can reach IMDS
+}
+
+type BlockDeviceMapping struct {
+    DeviceName string `yaml:"deviceName" validate:"required"`
+    VolumeSize int    `yaml:"volumeSize" validate:"required,min=8"`
+    VolumeType string `yaml:"volumeType" validate:"omitempty,oneof=gp2 gp3 io1 io2 st1 sc1"` // defaults to gp3
+    Iops       int    `yaml:"iops" validate:"omitempty,min=100"`
+    Throughput int    `yaml:"throughput" validate:"omitempty,min=125,max=1000"` // gp3 only
+    Encrypted  bool   `yaml:"encrypted"`
+    KmsKeyId   string `yaml:"kmsKeyId"`
+}
+
 func ReadNodeConfigs(nodeDirInClusterDir string) ([]NodeGroupConfig, error) {
+    // the warnings are reported by ValidateConfigTree
+    nodeGroupConfigs, _, err := readNodeConfigs(nodeDirInClusterDir)
+    return nodeGroupConfigs, err
+}
+
+// readNodeConfigs reads the nodegroups like ReadNodeConfigs, along with the warnings of the valid ones
+func readNodeConfigs(nodeDirInClusterDir string) ([]NodeGroupConfig, ValidationErrors, error) {
     var nodeGroupConfigs []NodeGroupConfig
+    var warnings ValidationErrors
 
-    // Walk through the cluster directory and its subdirectories
-    err := filepath.Walk(nodeDirInClusterDir, func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
+    // the environment and cluster defaults are merged under every nodegroup before validation
+    defaults, validationErrors := readNodeGroupDefaults(nodeDirInClusterDir)
+    templatesDir := filepath.Join(getEnvironmentDir(nodeDirInClusterDir), nodeGroupTemplatesDir)
 
-        // If the current file is a nodegroup yaml file, read it
-        if info.IsDir() || filepath.Ext(path) != ".yaml" {
-            return nil
-        }
+    // Only the yaml files directly inside the nodegroup directory are nodegroups, in a stable order
+    paths, err := listConfigFiles(nodeDirInClusterDir)
+    if err != nil {
+        return nil, nil, err
+    }
 
+    nodeGroupNames := newConfigNames("nodegroup")
+    for _, path := range paths {
         log.Printf("Reading nodegroup file: %s", path)
 
-        // Read the nodegroup yaml file
-        data, err := os.ReadFile(path)
-        if err != nil {
-            return err
-        }
-
-        // Unmarshal the YAML data into a NodeGroup struct
+        // Read, unmarshal and validate the nodegroup yaml file, the other files are still checked if it is invalid
         var nodeGroup NodeGroupConfig
-        err = yaml.Unmarshal(data, &nodeGroup)
-        if err != nil {
-            return err
+        document, errs := resolveNodeGroupDocument(path, defaults, templatesDir)
+        if document != nil {
+            errs = append(errs, decodeConfigDocument(document, &nodeGroup)...)
+            errs = append(errs, nodeGroupNames.add(nodeGroup.Name, document)...)
         }
-
-        // Validate the NodeGroup
-        err = ValidateConfigs(&nodeGroup)
-        if err != nil {
-            return err
+        errs, fileWarnings := errs.Split()
+        warnings = append(warnings, fileWarnings...)
+        if len(errs) > 0 {
+            validationErrors = append(validationErrors, errs...)
+            continue
         }
 
         // Add the NodeGroup to the slice
+        nodeGroup.document = document
         nodeGroupConfigs = append(nodeGroupConfigs, nodeGroup)
 
         log.Printf("Successfully read nodegroup file: %s", path)
+    }
 
-        return nil
-    })
-
-    if err != nil {
-        return nil, err
+    if len(validationErrors) > 0 {
+        return nil, warnings, validationErrors
     }
 
-    return nodeGroupConfigs, nil
+    return nodeGroupConfigs, warnings, nil
 }
\ No newline at end of file
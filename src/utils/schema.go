@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaEnums are the allowed values of the fields that are not validated with a oneof tag, by "Type.Field"
+var schemaEnums = map[string][]string{
+	"ComputeConfig.AmiType":      AmiTypes,
+	"ComputeConfig.CapacityType": CapacityTypes,
+	"MaximumUnavailable.Type":    MaximumUnavailableTypes,
+}
+
+// GenerateJSONSchema returns the JSON schema of the YAML file that is unmarshalled into config,
+// derived from the yaml and validate tags of its fields, for editor completion and validation
+func GenerateJSONSchema(config interface{}, title string) ([]byte, error) {
+	schema := getTypeSchema(reflect.TypeOf(config), nil)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = title
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// getTypeSchema returns the schema of a value of type t validated with tags, the tags after dive
+// apply to the items of slices and the values of maps
+func getTypeSchema(t reflect.Type, tags []string) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var itemTags []string
+	for i, tag := range tags {
+		if tag == "dive" {
+			tags, itemTags = tags[:i], tags[i+1:]
+			break
+		}
+	}
+
+	schema := map[string]interface{}{}
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if key == "-" || !field.IsExported() {
+				continue
+			}
+			fieldTags := strings.Split(field.Tag.Get("validate"), ",")
+			property := getTypeSchema(field.Type, fieldTags)
+			if enum, ok := schemaEnums[t.Name()+"."+field.Name]; ok {
+				property["enum"] = enum
+			}
+			properties[key] = property
+			if hasTag(fieldTags, "required") {
+				required = append(required, key)
+			}
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = getTypeSchema(t.Elem(), itemTags)
+		if hasTag(tags, "required") {
+			schema["minItems"] = 1
+		}
+		setSchemaLimits(schema, tags, "minItems", "maxItems")
+		// unique=Field compares a field of the items, which JSON schema can not express
+		for _, tag := range tags {
+			if tag == "unique" {
+				schema["uniqueItems"] = true
+			}
+		}
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = getTypeSchema(t.Elem(), itemTags)
+		if hasTag(tags, "required") {
+			schema["minProperties"] = 1
+		}
+	case reflect.String:
+		schema["type"] = "string"
+		if hasTag(tags, "required") {
+			schema["minLength"] = 1
+		}
+		setSchemaLimits(schema, tags, "minLength", "maxLength")
+		for _, tag := range tags {
+			name, param := splitTag(tag)
+			if pattern, ok := validationPatterns[name]; ok {
+				schema["pattern"] = pattern
+			}
+			switch name {
+			case "oneof":
+				schema["enum"] = strings.Fields(param)
+			case "cidrv4":
+				schema["pattern"] = `^(\d{1,3}\.){3}\d{1,3}/\d{1,2}$`
+			case "json":
+				schema["contentMediaType"] = "application/json"
+			case "tainteffect":
+				schema["enum"] = []string{"NO_SCHEDULE", "NO_EXECUTE", "PREFER_NO_SCHEDULE"}
+			case "addonname":
+				schema["enum"] = getSortedKeys(KnownAddons)
+			case "accesspolicy":
+				schema["enum"] = getSortedKeys(AccessPolicies)
+			case "kubernetesversion":
+				var versions []string
+				for _, kubernetesVersion := range GetKubernetesVersions() {
+					versions = append(versions, kubernetesVersion.Version)
+				}
+				schema["enum"] = versions
+			}
+		}
+	case reflect.Int:
+		schema["type"] = "integer"
+		setSchemaLimits(schema, tags, "minimum", "maximum")
+		for _, tag := range tags {
+			if name, param := splitTag(tag); name == "oneof" {
+				var enum []int
+				for _, value := range strings.Fields(param) {
+					number, _ := strconv.Atoi(value)
+					enum = append(enum, number)
+				}
+				schema["enum"] = enum
+			}
+		}
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	}
+	return schema
+}
+
+// setSchemaLimits translates the min and max tags into the given schema keywords
+func setSchemaLimits(schema map[string]interface{}, tags []string, minKeyword, maxKeyword string) {
+	for _, tag := range tags {
+		name, param := splitTag(tag)
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "min":
+			schema[minKeyword] = limit
+		case "max":
+			schema[maxKeyword] = limit
+		}
+	}
+}
+
+func splitTag(tag string) (string, string) {
+	name, param, _ := strings.Cut(tag, "=")
+	return name, param
+}
+
+func hasTag(tags []string, name string) bool {
+	for _, tag := range tags {
+		if tagName, _ := splitTag(tag); tagName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func getSortedKeys[T any](items map[string]T) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
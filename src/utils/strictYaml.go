@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// checkYamlKeys reports the keys of the YAML document that do not match a yaml tag of configType
+// and the keys defined twice in the same mapping, both of which decoding silently accepts
+func checkYamlKeys(document *configDocument, configType reflect.Type) ValidationErrors {
+	return checkYamlNodeKeys(document, document.Root, configType, "")
+}
+
+func checkYamlNodeKeys(document *configDocument, node *yamlv3.Node, nodeType reflect.Type, keyPath string) ValidationErrors {
+	for nodeType.Kind() == reflect.Ptr {
+		nodeType = nodeType.Elem()
+	}
+
+	var errs ValidationErrors
+	switch node.Kind {
+	case yamlv3.SequenceNode:
+		if nodeType.Kind() != reflect.Slice {
+			return nil
+		}
+		for i, item := range node.Content {
+			errs = append(errs, checkYamlNodeKeys(document, item, nodeType.Elem(), fmt.Sprintf("%s[%d]", keyPath, i))...)
+		}
+	case yamlv3.MappingNode:
+		if nodeType.Kind() != reflect.Struct && nodeType.Kind() != reflect.Map {
+			return nil
+		}
+		fields := getYamlFields(nodeType)
+		firstKeys := map[string]*yamlv3.Node{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			childPath := joinYamlKeyPath(keyPath, keyNode.Value)
+
+			if firstKey, ok := firstKeys[keyNode.Value]; ok {
+				errs = append(errs, ValidationError{
+					File:    document.getFile(keyNode),
+					Line:    keyNode.Line,
+					Column:  keyNode.Column,
+					Path:    childPath,
+					Rule:    "duplicate_key",
+					Message: fmt.Sprintf("key %q is already defined at line %d", keyNode.Value, firstKey.Line),
+				})
+				continue
+			}
+			firstKeys[keyNode.Value] = keyNode
+
+			if nodeType.Kind() == reflect.Map {
+				errs = append(errs, checkYamlNodeKeys(document, valueNode, nodeType.Elem(), childPath)...)
+				continue
+			}
+			fieldType, ok := fields[keyNode.Value]
+			if !ok {
+				errs = append(errs, ValidationError{
+					File:    document.getFile(keyNode),
+					Line:    keyNode.Line,
+					Column:  keyNode.Column,
+					Path:    childPath,
+					Rule:    "unknown_key",
+					Message: getUnknownKeyMessage(keyNode.Value, fields),
+				})
+				continue
+			}
+			errs = append(errs, checkYamlNodeKeys(document, valueNode, fieldType, childPath)...)
+		}
+	}
+	return errs
+}
+
+// getYamlFields returns the type of every field of structType by its yaml key
+func getYamlFields(structType reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	if structType.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "-" || !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		fields[key] = field.Type
+	}
+	return fields
+}
+
+func joinYamlKeyPath(keyPath, key string) string {
+	if keyPath == "" {
+		return key
+	}
+	return keyPath + "." + key
+}
+
+// getUnknownKeyMessage suggests the known key closest to key, or lists the known keys when none is close
+func getUnknownKeyMessage(key string, fields map[string]reflect.Type) string {
+	knownKeys := make([]string, 0, len(fields))
+	for knownKey := range fields {
+		knownKeys = append(knownKeys, knownKey)
+	}
+	sort.Strings(knownKeys)
+
+	suggestion := ""
+	bestDistance := 0
+	for _, knownKey := range knownKeys {
+		distance := getEditDistance(strings.ToLower(key), strings.ToLower(knownKey))
+		if suggestion == "" || distance < bestDistance {
+			suggestion, bestDistance = knownKey, distance
+		}
+	}
+	// a third of the key leaves room for a couple of typos without suggesting unrelated keys
+	if suggestion != "" && bestDistance <= len(key)/3+1 {
+		return fmt.Sprintf("unknown key %q, did you mean %q?", key, suggestion)
+	}
+	return fmt.Sprintf("unknown key %q, expected one of: %s", key, strings.Join(knownKeys, ", "))
+}
+
+// getEditDistance returns the Levenshtein distance between a and b
+func getEditDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
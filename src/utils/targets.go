@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+)
+
+// TargetConfig selects the clusters and nodegroups a run applies changes to, from the
+// include-clusters, exclude-clusters and include-nodegroups stack config keys
+type TargetConfig struct {
+	IncludeClusters   []string // glob patterns of cluster names, all clusters when empty
+	ExcludeClusters   []string // glob patterns of cluster names, applied after IncludeClusters
+	IncludeNodeGroups []string // glob patterns of nodegroup names or "<cluster>/<nodegroup>", all nodegroups when empty
+}
+
+// NewTargetConfig parses the stack config values, each a JSON list or a comma separated list of glob patterns
+func NewTargetConfig(includeClusters, excludeClusters, includeNodeGroups string) (TargetConfig, error) {
+	var targets TargetConfig
+	var err error
+	for _, value := range []struct {
+		key      string
+		value    string
+		patterns *[]string
+	}{
+		{"include-clusters", includeClusters, &targets.IncludeClusters},
+		{"exclude-clusters", excludeClusters, &targets.ExcludeClusters},
+		{"include-nodegroups", includeNodeGroups, &targets.IncludeNodeGroups},
+	} {
+		*value.patterns, err = parseTargetPatterns(value.key, value.value)
+		if err != nil {
+			return TargetConfig{}, err
+		}
+	}
+	return targets, nil
+}
+
+func parseTargetPatterns(key, value string) ([]string, error) {
+	var patterns []string
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &patterns); err != nil {
+			return nil, fmt.Errorf("%s must be a list of glob patterns: %w", key, err)
+		}
+	} else {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid glob pattern %q: %w", key, pattern, err)
+		}
+	}
+	return patterns, nil
+}
+
+// IsEmpty returns whether every cluster and nodegroup is targeted
+func (targets TargetConfig) IsEmpty() bool {
+	return len(targets.IncludeClusters) == 0 && len(targets.ExcludeClusters) == 0 && len(targets.IncludeNodeGroups) == 0
+}
+
+// GetClusterExclusion returns why the cluster is not targeted, or an empty string when it is
+func (targets TargetConfig) GetClusterExclusion(clusterName string) string {
+	if len(targets.IncludeClusters) > 0 && matchTargetPattern(targets.IncludeClusters, clusterName) == "" {
+		return "not matched by include-clusters"
+	}
+	if pattern := matchTargetPattern(targets.ExcludeClusters, clusterName); pattern != "" {
+		return fmt.Sprintf("matched by exclude-clusters pattern %q", pattern)
+	}
+	return ""
+}
+
+// GetNodeGroupExclusion returns why the nodegroup of the targeted cluster is not targeted, or an empty
+// string when it is, the patterns with a slash are matched against "<cluster>/<nodegroup>"
+func (targets TargetConfig) GetNodeGroupExclusion(clusterName, nodeGroupName string) string {
+	if len(targets.IncludeNodeGroups) == 0 {
+		return ""
+	}
+	for _, pattern := range targets.IncludeNodeGroups {
+		name := nodeGroupName
+		if strings.Contains(pattern, "/") {
+			name = clusterName + "/" + nodeGroupName
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return ""
+		}
+	}
+	return "not matched by include-nodegroups"
+}
+
+// matchTargetPattern returns the first pattern matching name, or an empty string
+func matchTargetPattern(patterns []string, name string) string {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// SelectClusters returns the clusters to register, the targeted ones and the excluded ones that are
+// deployed, with the names of the latter which must be left unchanged rather than deleted. deployed holds
+// the clusters exported by the last update and exists tells whether an excluded cluster missing from them
+// exists in AWS, in which case the outputs are missing or stale and the run is refused instead of skipping,
+// and deleting, the cluster. A deployed cluster whose config was removed is refused while it is excluded.
+func (targets TargetConfig) SelectClusters(clusterConfigs map[string]ClusterConfig, deployed map[string]bool, exists func(clusterName string) (bool, error)) (map[string]ClusterConfig, map[string]bool, error) {
+	for _, name := range getSortedKeys(deployed) {
+		if _, ok := clusterConfigs[name]; deployed[name] && !ok {
+			if reason := targets.GetClusterExclusion(name); reason != "" {
+				return nil, nil, fmt.Errorf("cluster %s is deployed but its config was removed, it would be deleted although it is excluded: %s", name, reason)
+			}
+		}
+	}
+
+	selected := map[string]ClusterConfig{}
+	unchanged := map[string]bool{}
+	for _, name := range GetClusterNames(clusterConfigs) {
+		reason := targets.GetClusterExclusion(name)
+		if reason == "" {
+			selected[name] = clusterConfigs[name]
+			continue
+		}
+		if deployed[name] {
+			log.Printf("Leaving cluster %s and its resources unchanged: %s, its nodegroups that are not deployed are skipped and the run is refused when its config adds or removes other resources", name, reason)
+			selected[name] = clusterConfigs[name]
+			unchanged[name] = true
+			continue
+		}
+		found, err := exists(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cluster %s is excluded and missing from the outputs of the stack, failed to check whether it exists: %w", name, err)
+		}
+		if found {
+			return nil, nil, fmt.Errorf("cluster %s is excluded and missing from the outputs of the stack although it exists, the outputs are missing or stale, run once without include-clusters and exclude-clusters to export them", name)
+		}
+		log.Printf("Skipping cluster %s, it is not deployed: %s", name, reason)
+	}
+	return selected, unchanged, nil
+}
+
+// SelectNodeGroups returns the nodegroups of the cluster to register, the targeted ones and the excluded ones that
+// are deployed, with the names of the latter which must be left unchanged rather than deleted, all of them are
+// excluded when the cluster is left unchanged. deployed holds the "<cluster>/<nodegroup>" keys of the deployed
+// nodegroups and exists tells whether an excluded nodegroup missing from them exists in AWS, the run is refused
+// when it does, and when a deployed nodegroup that is excluded had its file removed.
+func (targets TargetConfig) SelectNodeGroups(clusterName string, clusterUnchanged bool, nodeGroupConfigs []NodeGroupConfig, deployed map[string]bool, exists func(nodeGroupName string) (bool, error)) ([]NodeGroupConfig, map[string]bool, error) {
+	getExclusion := func(nodeGroupName string) string {
+		if clusterUnchanged {
+			return fmt.Sprintf("cluster %s is left unchanged", clusterName)
+		}
+		return targets.GetNodeGroupExclusion(clusterName, nodeGroupName)
+	}
+
+	configured := map[string]bool{}
+	for _, nodeGroupConfig := range nodeGroupConfigs {
+		configured[nodeGroupConfig.Name] = true
+	}
+	for _, key := range getSortedKeys(deployed) {
+		name, ok := strings.CutPrefix(key, clusterName+"/")
+		if !ok || !deployed[key] || configured[name] {
+			continue
+		}
+		if reason := getExclusion(name); reason != "" {
+			return nil, nil, fmt.Errorf("nodegroup %s is deployed but its file was removed, it would be deleted although it is excluded: %s", key, reason)
+		}
+	}
+
+	var selected []NodeGroupConfig
+	unchanged := map[string]bool{}
+	for _, nodeGroupConfig := range nodeGroupConfigs {
+		key := clusterName + "/" + nodeGroupConfig.Name
+		reason := getExclusion(nodeGroupConfig.Name)
+		if reason == "" {
+			selected = append(selected, nodeGroupConfig)
+			continue
+		}
+		if deployed[key] {
+			log.Printf("Leaving nodegroup %s unchanged: %s", key, reason)
+			selected = append(selected, nodeGroupConfig)
+			unchanged[nodeGroupConfig.Name] = true
+			continue
+		}
+		found, err := exists(nodeGroupConfig.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("nodegroup %s is excluded and missing from the outputs of the stack, failed to check whether it exists: %w", key, err)
+		}
+		if found {
+			return nil, nil, fmt.Errorf("nodegroup %s is excluded and missing from the outputs of the stack although it exists, the outputs are missing or stale, run once without include-clusters, exclude-clusters and include-nodegroups to export them", key)
+		}
+		log.Printf("Skipping nodegroup %s, it is not deployed: %s", key, reason)
+	}
+	return selected, unchanged, nil
+}
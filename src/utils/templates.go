@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// nodeGroupTemplatesDir is read from clusters/<env> and holds the nodegroup templates by name
+const nodeGroupTemplatesDir = "templates"
+
+// resolveNodeGroupDocument reads a nodegroup file and merges it over its defaults and its rendered template,
+// the document is nil when the file can not be parsed
+func resolveNodeGroupDocument(path string, defaults []*configDocument, templatesDir string) (*configDocument, ValidationErrors) {
+	document, errs := readConfigDocument(path)
+	if document == nil {
+		return nil, errs
+	}
+
+	errs = checkYamlKeys(document, reflect.TypeOf(NodeGroupConfig{}))
+
+	layers := append([]*configDocument{}, defaults...)
+	templateDocument, templateErrs := renderNodeGroupTemplate(document, templatesDir)
+	errs = append(errs, templateErrs...)
+	if templateDocument != nil {
+		layers = append(layers, templateDocument)
+	}
+	layers = append(layers, document)
+
+	return mergeConfigDocuments(layers...), errs
+}
+
+// renderNodeGroupTemplate renders the template referenced by the `template` key of the nodegroup document
+// with its `parameters`, nil when the nodegroup has no template or it can not be rendered
+func renderNodeGroupTemplate(document *configDocument, templatesDir string) (*configDocument, ValidationErrors) {
+	templateNode := getMappingValue(document.Root, "template")
+	if templateNode == nil || templateNode.Value == "" {
+		return nil, nil
+	}
+	parametersNode := getMappingValue(document.Root, "parameters")
+
+	newError := func(node *yamlv3.Node, path, message string) ValidationErrors {
+		return ValidationErrors{{File: document.Path, Line: node.Line, Column: node.Column, Path: path, Rule: "template", Message: message}}
+	}
+
+	name := templateNode.Value
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, newError(templateNode, "template", fmt.Sprintf("template %q must be the name of a file of %s without the .yaml extension", name, templatesDir))
+	}
+	templatePath := filepath.Join(templatesDir, name+".yaml")
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, newError(templateNode, "template", fmt.Sprintf("template %q not found, expected %s", name, templatePath))
+	}
+
+	log.Printf("Rendering nodegroup template: %s", templatePath)
+
+	parameters := map[string]string{}
+	if parametersNode != nil {
+		if err := parametersNode.Decode(&parameters); err != nil {
+			return nil, newError(parametersNode, "parameters", "must be a mapping of parameter names to strings")
+		}
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, ValidationErrors{{File: templatePath, Rule: "template", Message: err.Error()}}
+	}
+
+	// report every missing and unused parameter at once rather than the first one the execution stops at
+	used := map[string]bool{}
+	collectTemplateParameters(tmpl.Tree.Root, used)
+	var errs ValidationErrors
+	for _, parameter := range getSortedKeys(used) {
+		if _, ok := parameters[parameter]; !ok {
+			node := templateNode
+			if parametersNode != nil {
+				node = parametersNode
+			}
+			errs = append(errs, newError(node, "parameters", fmt.Sprintf("template %q requires parameter %q, which is not set", name, parameter))...)
+		}
+	}
+	for _, parameter := range getSortedKeys(parameters) {
+		if !used[parameter] {
+			keyPath := "parameters." + parameter
+			errs = append(errs, newError(findYamlNode(document.Root, []yamlKey{{Key: "parameters"}, {Key: parameter}}), keyPath, fmt.Sprintf("parameter %q is not used by template %q", parameter, name))...)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, parameters); err != nil {
+		return nil, ValidationErrors{{File: templatePath, Rule: "template", Message: err.Error()}}
+	}
+
+	var templateDocument yamlv3.Node
+	if err := yamlv3.Unmarshal(rendered.Bytes(), &templateDocument); err != nil {
+		return nil, getUnmarshalErrors(&configDocument{Path: templatePath}, err)
+	}
+	if len(templateDocument.Content) == 0 {
+		return nil, nil
+	}
+
+	result := &configDocument{Path: templatePath, Root: templateDocument.Content[0]}
+	return result, checkYamlKeys(result, reflect.TypeOf(NodeGroupConfig{}))
+}
+
+// collectTemplateParameters adds the fields of the template data used by node to parameters,
+// the bodies of range and with are skipped as they move the dot away from the parameters
+func collectTemplateParameters(node parse.Node, parameters map[string]bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			collectTemplateParameters(child, parameters)
+		}
+	case *parse.ActionNode:
+		collectTemplateParameters(node.Pipe, parameters)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, command := range node.Cmds {
+			for _, argument := range command.Args {
+				collectTemplateParameters(argument, parameters)
+			}
+		}
+	case *parse.FieldNode:
+		parameters[node.Ident[0]] = true
+	case *parse.IfNode:
+		collectTemplateParameters(node.Pipe, parameters)
+		collectTemplateParameters(node.List, parameters)
+		collectTemplateParameters(node.ElseList, parameters)
+	case *parse.RangeNode:
+		collectTemplateParameters(node.Pipe, parameters)
+		collectTemplateParameters(node.ElseList, parameters)
+	case *parse.WithNode:
+		collectTemplateParameters(node.Pipe, parameters)
+		collectTemplateParameters(node.ElseList, parameters)
+	}
+}
+
+// RenderedConfig is the resolved YAML of a config file after merging its defaults and template
+type RenderedConfig struct {
+	File string
+	YAML []byte
+}
+
+// RenderConfigTree returns the resolved YAML of every nodegroup of the clusters under rootDir,
+// as they are decoded after merging the defaults and rendering the templates
+func RenderConfigTree(rootDir string) ([]RenderedConfig, error) {
+	var rendered []RenderedConfig
+	var errs ValidationErrors
+
+	clusterDirs, err := listClusterDirs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, clusterDir := range clusterDirs {
+		nodeGroupDir := filepath.Join(clusterDir, "nodegroups")
+		nodeGroupFiles, err := listConfigFiles(nodeGroupDir)
+		if err != nil {
+			return nil, err
+		}
+		defaults, defaultsErrs := readNodeGroupDefaults(nodeGroupDir)
+		errs = append(errs, defaultsErrs...)
+		templatesDir := filepath.Join(getEnvironmentDir(nodeGroupDir), nodeGroupTemplatesDir)
+
+		for _, nodeGroupFile := range nodeGroupFiles {
+			document, documentErrs := resolveNodeGroupDocument(nodeGroupFile, defaults, templatesDir)
+			errs = append(errs, documentErrs...)
+			if document == nil {
+				continue
+			}
+
+			var buffer bytes.Buffer
+			encoder := yamlv3.NewEncoder(&buffer)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(document.Root); err != nil {
+				return nil, err
+			}
+			rendered = append(rendered, RenderedConfig{File: nodeGroupFile, YAML: buffer.Bytes()})
+		}
+	}
+	if len(errs) > 0 {
+		return rendered, removeDuplicateValidationErrors(errs)
+	}
+
+	return rendered, nil
+}
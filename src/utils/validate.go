@@ -1,13 +1,33 @@
 package utils
 
 import (
+	"fmt"
 	"log"
+	"net"
 	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
 
 	"github.com/go-playground/validator/v10"
 )
 
+// regular expressions of the custom string validations, shared with the JSON schema of the configs
+var validationPatterns = map[string]string{
+	"clustername":       `^[0-9A-Za-z][A-Za-z0-9_-]*$`,
+	"releaseversion":    `^1\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)-[0-9a-z]+$`,
+	"subnetid":          `^subnet-[a-fA-F0-9]{17}$`,
+	"instancetype":      `^[a-zA-Z0-9]+\.[a-zA-Z0-9]+$`,
+	"rolearn":           `^arn:aws:iam::\d{12}:role/.+$`,
+	"securitygroupid":   `^sg-[a-fA-F0-9]{17}$`,
+	"policyarn":         `^arn:aws:iam::(aws|\d{12}):policy/.+$`,
+	"thumbprint":        `^[a-fA-F0-9]{40}$`,
+	"amiid":             `^ami-([a-fA-F0-9]{8}|[a-fA-F0-9]{17})$`,
+	"principalarn":      `^arn:aws:iam::\d{12}:(role|user)/[\w+=,.@/-]+$`,
+	"addonversion":      `^(latest-compatible|v[0-9]+\.[0-9]+\.[0-9]+-eksbuild\.[0-9]+)$`,
+	"kmskeyarn":         `^arn:aws:kms:[a-z0-9-]+:\d{12}:key/([a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}|mrk-[a-f0-9]{32})$`,
+}
+
 // create a separate function for validation
 func ValidateConfigs(config interface{}) error {
 	log.Println("Checking the validation of the config: ", config)
@@ -20,6 +40,18 @@ func ValidateConfigs(config interface{}) error {
 	if err != nil {
 		return err
 	}
+	err = validate.RegisterValidation("clustername", validateClusterName)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("kubernetesversion", validateKubernetesVersion)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("releaseversion", validateReleaseVersion)
+	if err != nil {
+		return err
+	}
 	err = validate.RegisterValidation("subnetid", validateSubnetID)
 	if err != nil {
 		return err
@@ -40,6 +72,56 @@ func ValidateConfigs(config interface{}) error {
 	if err != nil {
 		return err
 	}
+	err = validate.RegisterValidation("amitype", validateEnum(AmiTypes))
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("capacitytype", validateEnum(CapacityTypes))
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("maxunavailabletype", validateEnum(MaximumUnavailableTypes))
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("policyarn", validatePolicyARN)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("thumbprint", validateThumbprint)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("addonname", validateAddonName)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("addonversion", validateAddonVersion)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("configurationvalues", validateConfigurationValues)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("amiid", validateAmiID)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("principalarn", validatePrincipalARN)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("accesspolicy", validateAccessPolicy)
+	if err != nil {
+		return err
+	}
+	err = validate.RegisterValidation("kmskeyarn", validateKmsKeyARN)
+	if err != nil {
+		return err
+	}
+	validate.RegisterStructValidation(validateClusterConfig, ClusterConfig{})
+	validate.RegisterStructValidation(validateNodeGroupConfig, NodeGroupConfig{})
 	err = validate.Struct(config)
 	if err != nil {
 		return err
@@ -48,6 +130,165 @@ func ValidateConfigs(config interface{}) error {
 	return nil
 }
 
+// custom struct level validation for the rules of ClusterConfig that span several fields
+func validateClusterConfig(sl validator.StructLevel) {
+	clusterConfig := sl.Current().Interface().(ClusterConfig)
+	for i, addon := range clusterConfig.Addons {
+		if !addon.CreateServiceAccountRole {
+			continue
+		}
+		fieldName := fmt.Sprintf("Addons[%d].CreateServiceAccountRole", i)
+		// the IRSA role of the add-on is trusted through the cluster OIDC provider
+		if clusterConfig.Oidc == nil {
+			sl.ReportError(addon.CreateServiceAccountRole, fieldName, fieldName, "required_oidc", "")
+		}
+		if definition, ok := KnownAddons[addon.Name]; ok && definition.ServiceAccount == nil {
+			sl.ReportError(addon.CreateServiceAccountRole, fieldName, fieldName, "addonserviceaccount", addon.Name)
+		}
+		if roleName := GetAddonRoleName(clusterConfig.Name, addon.Name); len(roleName) > MaxRoleNameLength {
+			sl.ReportError(addon.CreateServiceAccountRole, fieldName, fieldName, "addonrolename", roleName)
+		}
+	}
+	// IAM rejects role names above 64 characters, the default names grow with the cluster and service account names,
+	// a configured roleName is checked by its max tag
+	for i, serviceAccountRole := range clusterConfig.ServiceAccountRoles {
+		if serviceAccountRole.RoleName != "" {
+			continue
+		}
+		if roleName := serviceAccountRole.GetRoleName(clusterConfig.Name); len(roleName) > MaxRoleNameLength {
+			fieldName := fmt.Sprintf("ServiceAccountRoles[%d].RoleName", i)
+			sl.ReportError(serviceAccountRole.RoleName, fieldName, "RoleName", "irsarolename", roleName)
+		}
+	}
+	validateServiceIpv4Cidr(sl, clusterConfig)
+	// publicAccessCidrs only make sense while the public endpoint is enabled, and one endpoint has to stay reachable
+	if clusterConfig.IsEndpointPublicAccess() && len(clusterConfig.PublicAccessCidrs) == 0 {
+		sl.ReportError(clusterConfig.PublicAccessCidrs, "PublicAccessCidrs", "PublicAccessCidrs", "required", "")
+	}
+	if !clusterConfig.IsEndpointPublicAccess() && len(clusterConfig.PublicAccessCidrs) > 0 {
+		sl.ReportError(clusterConfig.PublicAccessCidrs, "PublicAccessCidrs", "PublicAccessCidrs", "excluded_without_publicaccess", "")
+	}
+	if !clusterConfig.IsEndpointPublicAccess() && !clusterConfig.IsEndpointPrivateAccess() {
+		sl.ReportError(clusterConfig.EndpointPrivateAccess, "EndpointPrivateAccess", "EndpointPrivateAccess", "required_without_publicaccess", "")
+	}
+	// the cluster log group is only created while logging is enabled
+	if len(clusterConfig.GetEnabledLogTypes()) == 0 && clusterConfig.LogRetentionInDays != 0 {
+		sl.ReportError(clusterConfig.LogRetentionInDays, "LogRetentionInDays", "LogRetentionInDays", "excluded_without_logtypes", "")
+	}
+	if len(clusterConfig.GetEnabledLogTypes()) == 0 && clusterConfig.LogKmsKeyArn != "" {
+		sl.ReportError(clusterConfig.LogKmsKeyArn, "LogKmsKeyArn", "LogKmsKeyArn", "excluded_without_logtypes", "")
+	}
+	// access entries are ignored by EKS while the cluster only authenticates through the aws-auth ConfigMap
+	if clusterConfig.Access != nil && clusterConfig.Access.AuthenticationMode == "CONFIG_MAP" && len(clusterConfig.Access.Entries) > 0 {
+		sl.ReportError(clusterConfig.Access.Entries, "Access.Entries", "Entries", "excluded_with_configmap", "")
+	}
+}
+
+// privateIpv4Blocks are the RFC 1918 blocks the service CIDR of a cluster has to be taken from
+var privateIpv4Blocks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+// validateServiceIpv4Cidr checks the EKS rules of the service CIDR, the format itself is checked by cidrv4
+func validateServiceIpv4Cidr(sl validator.StructLevel, clusterConfig ClusterConfig) {
+	ip, serviceCidr, err := net.ParseCIDR(clusterConfig.ServiceIpv4Cidr)
+	if err != nil || ip.To4() == nil || !ip.Equal(serviceCidr.IP) {
+		return
+	}
+
+	prefixLength, _ := serviceCidr.Mask.Size()
+	if prefixLength < 12 || prefixLength > 24 {
+		sl.ReportError(clusterConfig.ServiceIpv4Cidr, "ServiceIpv4Cidr", "ServiceIpv4Cidr", "servicecidrprefix", strconv.Itoa(prefixLength))
+	}
+	isPrivate := false
+	for _, block := range privateIpv4Blocks {
+		_, privateBlock, _ := net.ParseCIDR(block)
+		privatePrefixLength, _ := privateBlock.Mask.Size()
+		if privateBlock.Contains(serviceCidr.IP) && prefixLength >= privatePrefixLength {
+			isPrivate = true
+		}
+	}
+	if !isPrivate {
+		sl.ReportError(clusterConfig.ServiceIpv4Cidr, "ServiceIpv4Cidr", "ServiceIpv4Cidr", "servicecidrprivate", "")
+	}
+
+	// the service addresses must not be routed to nodes or pods of the VPC
+	for _, declared := range []struct {
+		tag   string
+		cidrs []string
+	}{{"servicecidrvpc", clusterConfig.VpcCidrs}, {"servicecidrpod", clusterConfig.PodCidrs}} {
+		for _, cidr := range declared.cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err == nil && (network.Contains(serviceCidr.IP) || serviceCidr.Contains(network.IP)) {
+				sl.ReportError(clusterConfig.ServiceIpv4Cidr, "ServiceIpv4Cidr", "ServiceIpv4Cidr", declared.tag, cidr)
+			}
+		}
+	}
+}
+
+// custom struct level validation for the rules of NodeGroupConfig that span several fields
+func validateNodeGroupConfig(sl validator.StructLevel) {
+	nodeGroupConfig := sl.Current().Interface().(NodeGroupConfig)
+
+	// EKS takes at most 100 nodes or percent of the nodes out of service at once, a number above maxSize would never apply
+	scalingConfig := nodeGroupConfig.ScalingConfiguration
+	maximumUnavailable := scalingConfig.MaximumUnavailable
+	switch {
+	case maximumUnavailable.Type == "percentage" && (maximumUnavailable.Value < 1 || maximumUnavailable.Value > 100):
+		sl.ReportError(maximumUnavailable.Value, "ScalingConfiguration.MaximumUnavailable.Value", "Value", "maxunavailablepercentage", "")
+	case maximumUnavailable.Type == "number" && (maximumUnavailable.Value < 1 || maximumUnavailable.Value > scalingConfig.MaxSize):
+		sl.ReportError(maximumUnavailable.Value, "ScalingConfiguration.MaximumUnavailable.Value", "Value", "maxunavailablenumber", strconv.Itoa(scalingConfig.MaxSize))
+	}
+
+	// EKS takes the version of a custom AMI from the AMI itself, and a release belongs to a single version
+	computeConfig := nodeGroupConfig.ComputeConfiguration
+	if computeConfig.AmiType == "CUSTOM" {
+		if computeConfig.Version != "" {
+			sl.ReportError(computeConfig.Version, "ComputeConfiguration.Version", "Version", "excluded_with_customami", "")
+		}
+		if computeConfig.ReleaseVersion != "" {
+			sl.ReportError(computeConfig.ReleaseVersion, "ComputeConfiguration.ReleaseVersion", "ReleaseVersion", "excluded_with_customami", "")
+		}
+	} else if computeConfig.Version != "" && computeConfig.ReleaseVersion != "" && !isReleaseOfVersion(computeConfig.ReleaseVersion, computeConfig.Version) {
+		sl.ReportError(computeConfig.ReleaseVersion, "ComputeConfiguration.ReleaseVersion", "ReleaseVersion", "releaseofversion", computeConfig.Version)
+	}
+
+	launchTemplate := nodeGroupConfig.LaunchTemplate
+	if launchTemplate == nil {
+		// without a launch template EKS needs the disk size and ssh key on the nodegroup itself
+		if nodeGroupConfig.ComputeConfiguration.DiskSize == 0 {
+			sl.ReportError(nodeGroupConfig.ComputeConfiguration.DiskSize, "ComputeConfiguration.DiskSize", "DiskSize", "required", "")
+		}
+		if nodeGroupConfig.NetworkConfiguration.Ec2KeyPair == "" {
+			sl.ReportError(nodeGroupConfig.NetworkConfiguration.Ec2KeyPair, "NetworkConfiguration.Ec2KeyPair", "Ec2KeyPair", "required", "")
+		}
+		if nodeGroupConfig.ComputeConfiguration.AmiType == "CUSTOM" {
+			sl.ReportError(nodeGroupConfig.ComputeConfiguration.AmiType, "ComputeConfiguration.AmiType", "AmiType", "required_launchtemplate", "")
+		}
+		return
+	}
+
+	// EKS rejects disk size and remote access when they are also defined by the launch template
+	if nodeGroupConfig.ComputeConfiguration.DiskSize != 0 {
+		sl.ReportError(nodeGroupConfig.ComputeConfiguration.DiskSize, "ComputeConfiguration.DiskSize", "DiskSize", "excluded_with_launchtemplate", "")
+	}
+	if nodeGroupConfig.NetworkConfiguration.Ec2KeyPair != "" {
+		sl.ReportError(nodeGroupConfig.NetworkConfiguration.Ec2KeyPair, "NetworkConfiguration.Ec2KeyPair", "Ec2KeyPair", "excluded_with_launchtemplate", "")
+	}
+	// a custom AMI is only accepted with the CUSTOM ami type and the other way around
+	if (launchTemplate.ImageId != "") != (nodeGroupConfig.ComputeConfiguration.AmiType == "CUSTOM") {
+		sl.ReportError(launchTemplate.ImageId, "LaunchTemplate.ImageId", "ImageId", "customami", nodeGroupConfig.ComputeConfiguration.AmiType)
+	}
+	// EKS does not bootstrap the nodes of a custom AMI, without user data joining the cluster they never become ready
+	if nodeGroupConfig.ComputeConfiguration.AmiType == "CUSTOM" && launchTemplate.UserData == "" {
+		sl.ReportError(launchTemplate.UserData, "LaunchTemplate.UserData", "UserData", "required_customami", "")
+	}
+	for i, blockDeviceMapping := range launchTemplate.BlockDeviceMappings {
+		if blockDeviceMapping.Throughput != 0 && blockDeviceMapping.VolumeType != "" && blockDeviceMapping.VolumeType != "gp3" {
+			fieldName := fmt.Sprintf("LaunchTemplate.BlockDeviceMappings[%d].Throughput", i)
+			sl.ReportError(blockDeviceMapping.Throughput, fieldName, "Throughput", "gp3only", blockDeviceMapping.VolumeType)
+		}
+	}
+}
+
 // custom validation function to validate MaxSize field based on MinSize field: MaxSize >= MinSize
 func validateMaxSize(fl validator.FieldLevel) bool {
     minFieldName := fl.Param()
@@ -76,7 +317,7 @@ func validateMinSize(fl validator.FieldLevel) bool {
 func validateSubnetID(fl validator.FieldLevel) bool {
     subnetID := fl.Field().String()
     // AWS subnet IDs start with "subnet-" followed by a 17-character hexadecimal string
-    matched, _ := regexp.MatchString(`^subnet-[a-fA-F0-9]{17}$`, subnetID)
+    matched, _ := regexp.MatchString(validationPatterns["subnetid"], subnetID)
     return matched
 }
 
@@ -85,7 +326,7 @@ func validateInstanceType(fl validator.FieldLevel) bool {
     instanceType := fl.Field().String()
     // AWS instance types are in the format "t2.micro", "m5.large", "t4g.nano", etc.
 	// log.Println("Instance type: ", instanceType)
-	matched, _ := regexp.MatchString(`^[a-zA-Z0-9]+\.[a-zA-Z0-9]+$`, instanceType)
+	matched, _ := regexp.MatchString(validationPatterns["instancetype"], instanceType)
     return matched
 }
 
@@ -93,7 +334,7 @@ func validateInstanceType(fl validator.FieldLevel) bool {
 func validateRoleARN(fl validator.FieldLevel) bool {
     roleARN := fl.Field().String()
     // AWS IAM role ARNs are in the format "arn:aws:iam::123456789012:role/MyRole"
-    matched, _ := regexp.MatchString(`^arn:aws:iam::\d{12}:role/.+$`, roleARN)
+    matched, _ := regexp.MatchString(validationPatterns["rolearn"], roleARN)
     return matched
 }
 
@@ -102,7 +343,7 @@ func validateSecurityGroupID(fl validator.FieldLevel) bool {
 	securityGroupID := fl.Field().String()
 	// AWS security group IDs start with "sg-" followed by a 17-character hexadecimal string
 	// log.Println("Security group ID: ", securityGroupID)
-	matched, _ := regexp.MatchString(`^sg-[a-fA-F0-9]{17}$`, securityGroupID)
+	matched, _ := regexp.MatchString(validationPatterns["securitygroupid"], securityGroupID)
 	return matched
 }
 
@@ -112,4 +353,87 @@ func validateTaintEffect(fl validator.FieldLevel) bool {
 	// Kubernetes taint effects can be "NoSchedule", "PreferNoSchedule", or "NoExecute"
 	// log.Println("Taint effect: ", taintEffect)
 	return taintEffect == "NO_SCHEDULE" || taintEffect == "NO_EXECUTE" || taintEffect == "PREFER_NO_SCHEDULE"
-}
\ No newline at end of file
+}
+
+// custom validation function for the Kubernetes version of a cluster, the versions older than the EKS version list are not supported
+func validateKubernetesVersion(fl validator.FieldLevel) bool {
+	return isSupportedKubernetesVersion(fl.Field().String())
+}
+
+// custom validation functions for nodegroup AMI release version field
+func validateReleaseVersion(fl validator.FieldLevel) bool {
+	releaseVersion := fl.Field().String()
+	// EKS AMI releases are the Kubernetes patch version followed by the build date or commit, like "1.29.3-20240514"
+	matched, _ := regexp.MatchString(validationPatterns["releaseversion"], releaseVersion)
+	return matched
+}
+
+// validateEnum returns a validation function accepting only the values, in their case
+func validateEnum(values []string) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return slices.Contains(values, fl.Field().String())
+	}
+}
+
+// custom validation functions for managed policy ARN field
+func validatePolicyARN(fl validator.FieldLevel) bool {
+	policyARN := fl.Field().String()
+	// AWS IAM policy ARNs are in the format "arn:aws:iam::aws:policy/MyPolicy" or "arn:aws:iam::123456789012:policy/MyPolicy"
+	matched, _ := regexp.MatchString(validationPatterns["policyarn"], policyARN)
+	return matched
+}
+
+// custom validation functions for OIDC thumbprint field
+func validateThumbprint(fl validator.FieldLevel) bool {
+	thumbprint := fl.Field().String()
+	// OIDC thumbprints are the 40-character hex encoded SHA-1 of the root CA certificate
+	matched, _ := regexp.MatchString(validationPatterns["thumbprint"], thumbprint)
+	return matched
+}
+
+
+// custom validation functions for AMI ID field
+func validateAmiID(fl validator.FieldLevel) bool {
+	amiID := fl.Field().String()
+	// AWS AMI IDs start with "ami-" followed by an 8 or 17-character hexadecimal string
+	matched, _ := regexp.MatchString(validationPatterns["amiid"], amiID)
+	return matched
+}
+
+// custom validation functions for access entry principal ARN field
+func validatePrincipalARN(fl validator.FieldLevel) bool {
+	principalARN := fl.Field().String()
+	// access entries take IAM role or user ARNs like "arn:aws:iam::123456789012:role/MyRole", never STS assumed-role ARNs
+	matched, _ := regexp.MatchString(validationPatterns["principalarn"], principalARN)
+	return matched
+}
+
+// custom validation functions for access policy name field
+func validateAccessPolicy(fl validator.FieldLevel) bool {
+	_, ok := AccessPolicies[fl.Field().String()]
+	return ok
+}
+
+// custom validation functions for add-on version field
+func validateAddonVersion(fl validator.FieldLevel) bool {
+	addonVersion := fl.Field().String()
+	// EKS add-on versions are like "v1.18.1-eksbuild.1", "latest-compatible" is resolved on every run
+	matched, _ := regexp.MatchString(validationPatterns["addonversion"], addonVersion)
+	return matched
+}
+
+// custom validation functions for cluster name field
+func validateClusterName(fl validator.FieldLevel) bool {
+	clusterName := fl.Field().String()
+	// EKS cluster names start with a letter or digit followed by letters, digits, hyphens and underscores
+	matched, _ := regexp.MatchString(validationPatterns["clustername"], clusterName)
+	return matched
+}
+
+// custom validation functions for KMS key ARN field
+func validateKmsKeyARN(fl validator.FieldLevel) bool {
+	kmsKeyARN := fl.Field().String()
+	// AWS KMS key ARNs are in the format "arn:aws:kms:ap-south-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	matched, _ := regexp.MatchString(validationPatterns["kmskeyarn"], kmsKeyARN)
+	return matched
+}
@@ -1,7 +1,12 @@
 package utils_test
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dreamplug-tech/eks-iaac-2.0/src/utils"
 	"github.com/stretchr/testify/require"
@@ -9,11 +14,17 @@ import (
 
 func TestValidate(t *testing.T) {
     t.Parallel()
+    // the end of support of the cluster versions is checked on a fixed date
+    options := utils.TreeOptions{SupportPolicy: utils.VersionPolicy{
+        WarningDays: utils.DefaultVersionWarningDays,
+        Now:         func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) },
+    }}
+
     // Test case when a required field is missing
     t.Run("TestClusterConfigNameIsMissing", func(t *testing.T) {
         config := utils.ClusterConfig{
             // Don't set the Name field
-            Version:           "1.18",
+            Version:           "1.29",
         }
         err := utils.ValidateConfigs(config)
         require.Error(t, err)
@@ -24,7 +35,7 @@ func TestValidate(t *testing.T) {
     t.Run("TestClusterConfigNameIsEmpty", func(t *testing.T) {
         config := utils.ClusterConfig{
             Name:             "",
-            Version:           "1.18",
+            Version:           "1.29",
         }
         err := utils.ValidateConfigs(config)
         require.Error(t, err)
@@ -430,7 +441,7 @@ func TestValidate(t *testing.T) {
     t.Run("TestValidSecurityGroupID", func(t *testing.T) {
         cluster := utils.ClusterConfig{
             Name:             "my-cluster",
-            Version:           "1.18",
+            Version:           "1.29",
             RoleArn:          "arn:aws:iam::123456789012:role/eks-cluster-role",
             SubnetIds:        []string{"subnet-12345678912345678"},
             SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
@@ -446,7 +457,7 @@ func TestValidate(t *testing.T) {
     t.Run("TestInvalidSecurityGroupID", func(t *testing.T) {
         cluster := utils.ClusterConfig{
             Name:             "my-cluster",
-            Version:           "1.18",
+            Version:           "1.29",
             RoleArn:          "arn:aws:iam::123456789012:role/eks-cluster-role",
             SubnetIds:        []string{"subnet-027691384e95e1c10"},
             SecurityGroupIds: []string{"sg-12345"},
@@ -575,4 +586,1725 @@ func TestValidate(t *testing.T) {
         require.NoError(t, err)
         // require.Contains(t, err.Error(), "DesiredCapacity is between MinSize and MaxSize")
     })
+
+    t.Run("TestServiceAccountRolesWithoutOidc", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            ServiceAccountRoles: []utils.ServiceAccountRole{
+                {
+                    Namespace:          "kube-system",
+                    ServiceAccountName: "cluster-autoscaler",
+                },
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+    })
+
+    t.Run("TestValidServiceAccountRole", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Oidc:            &utils.OidcConfig{},
+            ServiceAccountRoles: []utils.ServiceAccountRole{
+                {
+                    Namespace:          "kube-system",
+                    ServiceAccountName: "cluster-autoscaler",
+                    ManagedPolicyArns:  []string{"arn:aws:iam::aws:policy/AutoScalingFullAccess"},
+                    InlinePolicy:       `{"Version": "2012-10-17", "Statement": []}`,
+                },
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.NoError(t, err)
+    })
+
+    t.Run("TestInvalidServiceAccountRolePolicies", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Oidc:            &utils.OidcConfig{Thumbprints: []string{"not-a-thumbprint"}},
+            ServiceAccountRoles: []utils.ServiceAccountRole{
+                {
+                    Namespace:          "kube-system",
+                    ServiceAccountName: "cluster-autoscaler",
+                    ManagedPolicyArns:  []string{"AutoScalingFullAccess"},
+                    InlinePolicy:       `{"Version": `,
+                },
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'Thumbprints[0]' failed on the 'thumbprint' tag")
+        require.Contains(t, err.Error(), "Field validation for 'ManagedPolicyArns[0]' failed on the 'policyarn' tag")
+        require.Contains(t, err.Error(), "Field validation for 'InlinePolicy' failed on the 'json' tag")
+    })
+
+    t.Run("TestServiceAccountRoleNameLength", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "payments-platform-production",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Oidc:            &utils.OidcConfig{},
+            ServiceAccountRoles: []utils.ServiceAccountRole{
+                {Namespace: "kube-system", ServiceAccountName: "cluster-autoscaler"},
+                {Namespace: "kube-system", ServiceAccountName: "cluster-autoscaler", RoleName: "payments-cluster-autoscaler"},
+                {Namespace: "kube-system", ServiceAccountName: "external-dns", RoleName: strings.Repeat("r", 65)},
+            },
+            Addons: []utils.AddonConfig{
+                {Name: "vpc-cni", CreateServiceAccountRole: true},
+            },
+        }
+        require.Equal(t, "payments-platform-production-kube-system-cluster-autoscaler-irsa-role", cluster.ServiceAccountRoles[0].GetRoleName(cluster.Name))
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'ServiceAccountRoles[0].RoleName' failed on the 'irsarolename' tag")
+        require.NotContains(t, err.Error(), "ServiceAccountRoles[1]")
+        // a configured name is only checked by its max tag, not as the default name
+        require.Contains(t, err.Error(), "Field validation for 'RoleName' failed on the 'max' tag")
+        require.NotContains(t, err.Error(), "Field validation for 'ServiceAccountRoles[2].RoleName' failed on the 'irsarolename' tag")
+
+        // the add-on role fits, its name only carries the cluster and add-on names
+        require.NotContains(t, err.Error(), "addonrolename")
+        cluster.Name = strings.Repeat("a", 50)
+        err = utils.ValidateConfigs(cluster)
+        require.Contains(t, err.Error(), "Field validation for 'Addons[0].CreateServiceAccountRole' failed on the 'addonrolename' tag")
+    })
+
+    t.Run("TestValidAddons", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Oidc:            &utils.OidcConfig{},
+            Addons: []utils.AddonConfig{
+                {Name: "vpc-cni", Version: "latest-compatible", CreateServiceAccountRole: true},
+                {Name: "coredns", ResolveConflicts: "OVERWRITE", ConfigurationValues: "replicaCount: 3"},
+                {Name: "kube-proxy", Version: "v1.29.0-eksbuild.1"},
+                {Name: "aws-ebs-csi-driver", ServiceAccountRoleArn: "arn:aws:iam::123456789012:role/ebs-csi", ConfigurationValues: `{"controller": {"replicaCount": 2}}`},
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.NoError(t, err)
+    })
+
+    t.Run("TestInvalidAddons", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Addons: []utils.AddonConfig{
+                {Name: "vpc-cnii"},
+                {Name: "coredns", Version: "default", ConfigurationValues: `{"replicaCount": }`},
+                {Name: "aws-ebs-csi-driver", CreateServiceAccountRole: true},
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'Name' failed on the 'addonname' tag")
+        require.Contains(t, err.Error(), "Field validation for 'Version' failed on the 'addonversion' tag")
+        require.Contains(t, err.Error(), "Field validation for 'ConfigurationValues' failed on the 'configurationvalues' tag")
+        require.Contains(t, err.Error(), "Field validation for 'Addons[2].CreateServiceAccountRole' failed on the 'required_oidc' tag")
+    })
+
+    t.Run("TestConvertAddonConfigurationValuesToJSON", func(t *testing.T) {
+        values, err := utils.ConvertConfigurationValuesToJSON("resources:\n  limits:\n    memory: 170Mi\nreplicaCount: 3\n")
+        require.NoError(t, err)
+        require.JSONEq(t, `{"resources": {"limits": {"memory": "170Mi"}}, "replicaCount": 3}`, values)
+    })
+
+    t.Run("TestValidLaunchTemplate", func(t *testing.T) {
+        nodeGroup := utils.NodeGroupConfig{
+            Name:            "my-node-group",
+            ScalingConfiguration: utils.ScalingConfig{
+                DesiredCapacity: 1,
+                MinSize:         1,
+                MaxSize:         2,
+                MaximumUnavailable: utils.MaximumUnavailable{
+                    Type:  "percentage",
+                    Value: 50,
+                },
+            },
+            NetworkConfiguration: utils.NetworkConfig{
+                SubnetIds:      []string{"subnet-12345678912345678"},
+                Ec2KeyPair:     "",
+                SecurityGroupIds: []string{"sg-12345678912345678"},
+            },
+            ComputeConfiguration: utils.ComputeConfig{
+                AmiType:        "AL2023_x86_64_STANDARD",
+                CapacityType:   "ON_DEMAND",
+                InstanceTypes:  []string{"t3.medium"},
+                DiskSize:       0,
+            },
+            Tags:           map[string]string{"key": "value"},
+            KubernetesLabels: map[string]string{"key": "value"},
+            LaunchTemplate: &utils.LaunchTemplateConfig{
+                KeyName: "my-key-pair",
+                MetadataOptions: utils.MetadataOptions{HttpTokens: "required", HttpPutResponseHopLimit: 2},
+                BlockDeviceMappings: []utils.BlockDeviceMapping{
+                    {DeviceName: "/dev/xvda", VolumeSize: 50, VolumeType: "gp3", Iops: 3000, Throughput: 250, Encrypted: true},
+                },
+                UserData: "#!/bin/bash\necho hello",
+            },
+        }
+        err := utils.ValidateConfigs(nodeGroup)
+        require.NoError(t, err)
+    })
+
+    t.Run("TestLaunchTemplateWithDiskSizeAndRemoteAccess", func(t *testing.T) {
+        nodeGroup := utils.NodeGroupConfig{
+            Name:            "my-node-group",
+            ScalingConfiguration: utils.ScalingConfig{
+                DesiredCapacity: 1,
+                MinSize:         1,
+                MaxSize:         2,
+                MaximumUnavailable: utils.MaximumUnavailable{
+                    Type:  "percentage",
+                    Value: 50,
+                },
+            },
+            NetworkConfiguration: utils.NetworkConfig{
+                SubnetIds:      []string{"subnet-12345678912345678"},
+                Ec2KeyPair:     "my-key-pair",
+                SecurityGroupIds: []string{"sg-12345678912345678"},
+            },
+            ComputeConfiguration: utils.ComputeConfig{
+                AmiType:        "AL2_x86_64",
+                CapacityType:   "ON_DEMAND",
+                InstanceTypes:  []string{"t3.medium"},
+                DiskSize:       20,
+            },
+            Tags:           map[string]string{"key": "value"},
+            KubernetesLabels: map[string]string{"key": "value"},
+            LaunchTemplate: &utils.LaunchTemplateConfig{
+            },
+        }
+        err := utils.ValidateConfigs(nodeGroup)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'ComputeConfiguration.DiskSize' failed on the 'excluded_with_launchtemplate' tag")
+        require.Contains(t, err.Error(), "Field validation for 'NetworkConfiguration.Ec2KeyPair' failed on the 'excluded_with_launchtemplate' tag")
+    })
+
+    t.Run("TestLaunchTemplateCustomAmiWithoutCustomAmiType", func(t *testing.T) {
+        nodeGroup := utils.NodeGroupConfig{
+            Name:            "my-node-group",
+            ScalingConfiguration: utils.ScalingConfig{
+                DesiredCapacity: 1,
+                MinSize:         1,
+                MaxSize:         2,
+                MaximumUnavailable: utils.MaximumUnavailable{
+                    Type:  "percentage",
+                    Value: 50,
+                },
+            },
+            NetworkConfiguration: utils.NetworkConfig{
+                SubnetIds:      []string{"subnet-12345678912345678"},
+                Ec2KeyPair:     "",
+                SecurityGroupIds: []string{"sg-12345678912345678"},
+            },
+            ComputeConfiguration: utils.ComputeConfig{
+                AmiType:        "AL2_x86_64",
+                CapacityType:   "ON_DEMAND",
+                InstanceTypes:  []string{"t3.medium"},
+                DiskSize:       0,
+            },
+            Tags:           map[string]string{"key": "value"},
+            KubernetesLabels: map[string]string{"key": "value"},
+            LaunchTemplate: &utils.LaunchTemplateConfig{
+                ImageId: "ami-0123456789abcdef0",
+                BlockDeviceMappings: []utils.BlockDeviceMapping{
+                    {DeviceName: "/dev/xvda", VolumeSize: 50, VolumeType: "gp2", Throughput: 250},
+                },
+            },
+        }
+        err := utils.ValidateConfigs(nodeGroup)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'LaunchTemplate.ImageId' failed on the 'customami' tag")
+        require.Contains(t, err.Error(), "Field validation for 'LaunchTemplate.BlockDeviceMappings[0].Throughput' failed on the 'gp3only' tag")
+    })
+
+    t.Run("TestLaunchTemplateCustomAmiWithoutUserData", func(t *testing.T) {
+        nodeGroup := utils.NodeGroupConfig{
+            Name:            "my-node-group",
+            ScalingConfiguration: utils.ScalingConfig{
+                DesiredCapacity: 1,
+                MinSize:         1,
+                MaxSize:         2,
+                MaximumUnavailable: utils.MaximumUnavailable{
+                    Type:  "percentage",
+                    Value: 50,
+                },
+            },
+            NetworkConfiguration: utils.NetworkConfig{
+                SubnetIds:      []string{"subnet-12345678912345678"},
+                SecurityGroupIds: []string{"sg-12345678912345678"},
+            },
+            ComputeConfiguration: utils.ComputeConfig{
+                AmiType:        "CUSTOM",
+                CapacityType:   "ON_DEMAND",
+                InstanceTypes:  []string{"t3.medium"},
+            },
+            Tags:           map[string]string{"key": "value"},
+            KubernetesLabels: map[string]string{"key": "value"},
+            LaunchTemplate: &utils.LaunchTemplateConfig{
+                ImageId: "ami-0123456789abcdef0",
+            },
+        }
+        err := utils.ValidateConfigs(nodeGroup)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'LaunchTemplate.UserData' failed on the 'required_customami' tag")
+
+        nodeGroup.LaunchTemplate.UserData = "#!/bin/bash\n/etc/eks/bootstrap.sh my-cluster\n"
+        require.NoError(t, utils.ValidateConfigs(nodeGroup))
+    })
+
+    t.Run("TestValidFargateProfile", func(t *testing.T) {
+        fargateProfile := utils.FargateProfileConfig{
+            Name:      "ci-runners",
+            Selectors: []utils.FargateSelector{
+                {Namespace: "ci", Labels: map[string]string{"runner": "fargate"}},
+            },
+            SubnetIds: []string{"subnet-12345678912345678"},
+        }
+        err := utils.ValidateConfigs(fargateProfile)
+        require.NoError(t, err)
+    })
+
+    t.Run("TestFargateProfileWithoutSelectors", func(t *testing.T) {
+        fargateProfile := utils.FargateProfileConfig{
+            Name:                "ci-runners",
+            SubnetIds:           []string{"subnet-12345678912345678"},
+            PodExecutionRoleArn: "fargate-role",
+        }
+        err := utils.ValidateConfigs(fargateProfile)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'Selectors' failed on the 'required' tag")
+        require.Contains(t, err.Error(), "Field validation for 'PodExecutionRoleArn' failed on the 'rolearn' tag")
+    })
+
+    t.Run("TestValidAccessEntries", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Access: &utils.AccessConfig{
+                AuthenticationMode: "API_AND_CONFIG_MAP",
+                Entries: []utils.AccessEntryConfig{
+                    {
+                        PrincipalArn: "arn:aws:iam::123456789012:role/sre",
+                        Policies:     []utils.AccessPolicyConfig{{Name: "AmazonEKSClusterAdminPolicy", Scope: "cluster"}},
+                    },
+                    {
+                        PrincipalArn: "arn:aws:iam::123456789012:role/developers",
+                        Policies:     []utils.AccessPolicyConfig{{Name: "AmazonEKSEditPolicy", Scope: "namespace", Namespaces: []string{"dev"}}},
+                    },
+                },
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.NoError(t, err)
+    })
+
+    t.Run("TestInvalidAccessEntries", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Access: &utils.AccessConfig{
+                Entries: []utils.AccessEntryConfig{
+                    {
+                        PrincipalArn: "arn:aws:sts::123456789012:assumed-role/sre/session",
+                        Policies:     []utils.AccessPolicyConfig{{Name: "AmazonEKSRootPolicy", Scope: "namespace"}},
+                    },
+                    {
+                        PrincipalArn: "arn:aws:iam::123456789012:role/sre",
+                        Policies:     []utils.AccessPolicyConfig{{Name: "AmazonEKSViewPolicy", Scope: "cluster", Namespaces: []string{"dev"}}},
+                    },
+                },
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'PrincipalArn' failed on the 'principalarn' tag")
+        require.Contains(t, err.Error(), "Field validation for 'Name' failed on the 'accesspolicy' tag")
+        require.Contains(t, err.Error(), "Field validation for 'Namespaces' failed on the 'required_if' tag")
+        require.Contains(t, err.Error(), "Field validation for 'Namespaces' failed on the 'excluded_if' tag")
+    })
+
+    t.Run("TestDuplicateAccessEntryPrincipals", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Access: &utils.AccessConfig{
+                Entries: []utils.AccessEntryConfig{
+                    {PrincipalArn: "arn:aws:iam::123456789012:role/sre"},
+                    {PrincipalArn: "arn:aws:iam::123456789012:role/sre"},
+                },
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'Entries' failed on the 'unique' tag")
+    })
+
+    t.Run("TestValidEncryptionKmsKeyArn", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Encryption: &utils.EncryptionConfig{
+                KmsKeyArn: "arn:aws:kms:ap-south-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.NoError(t, err)
+    })
+
+    t.Run("TestInvalidEncryptionKmsKeyArn", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            Encryption: &utils.EncryptionConfig{
+                KmsKeyArn: "arn:aws:kms:ap-south-1:123456789012:alias/eks",
+            },
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'KmsKeyArn' failed on the 'kmskeyarn' tag")
+    })
+
+    t.Run("TestValidPrivateEndpointAndLogging", func(t *testing.T) {
+        enabled, disabled := true, false
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            EndpointPrivateAccess: &enabled,
+            EndpointPublicAccess:  &disabled,
+            EnabledLogTypes:       []string{"api", "audit"},
+            LogRetentionInDays:    30,
+            LogKmsKeyArn:          "arn:aws:kms:ap-south-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.NoError(t, err)
+    })
+
+    t.Run("TestPublicAccessCidrsWithPublicAccessDisabled", func(t *testing.T) {
+        disabled := false
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            PublicAccessCidrs:     []string{"10.0.0.0/16"},
+            EndpointPublicAccess:  &disabled,
+            EnabledLogTypes:       []string{"api", "kubelet"},
+            LogRetentionInDays:    10,
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'PublicAccessCidrs' failed on the 'excluded_without_publicaccess' tag")
+        require.Contains(t, err.Error(), "Field validation for 'EndpointPrivateAccess' failed on the 'required_without_publicaccess' tag")
+        require.Contains(t, err.Error(), "Field validation for 'EnabledLogTypes[1]' failed on the 'oneof' tag")
+        require.Contains(t, err.Error(), "Field validation for 'LogRetentionInDays' failed on the 'oneof' tag")
+    })
+
+    t.Run("TestLogSettingsWithLoggingDisabled", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            PublicAccessCidrs: []string{"10.0.0.0/16"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+            EnabledLogTypes:       []string{},
+            LogRetentionInDays:    30,
+            LogKmsKeyArn:          "arn:aws:kms:ap-south-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'LogRetentionInDays' failed on the 'excluded_without_logtypes' tag")
+        require.Contains(t, err.Error(), "Field validation for 'LogKmsKeyArn' failed on the 'excluded_without_logtypes' tag")
+    })
+
+    t.Run("TestClusterLogGroup", func(t *testing.T) {
+        // EKS creates the log group of a cluster that logs without a retention or KMS key
+        require.False(t, utils.ClusterConfig{}.HasClusterLogGroup())
+        require.True(t, utils.ClusterConfig{LogRetentionInDays: 30}.HasClusterLogGroup())
+        require.True(t, utils.ClusterConfig{LogKmsKeyArn: "arn:aws:kms:ap-south-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"}.HasClusterLogGroup())
+        require.False(t, utils.ClusterConfig{EnabledLogTypes: []string{}, LogRetentionInDays: 30}.HasClusterLogGroup())
+    })
+
+    t.Run("TestPublicAccessCidrsRequiredWithPublicAccess", func(t *testing.T) {
+        cluster := utils.ClusterConfig{
+            Name:             "my-cluster",
+            Version:           "1.29",
+            SubnetIds:        []string{"subnet-12345678912345678"},
+            SecurityGroupIds: []string{"sg-0f3a7d6b8e5c4e5c9"},
+            Tags:            map[string]string{"key": "value"},
+            ServiceIpv4Cidr: "172.20.0.0/16",
+        }
+        err := utils.ValidateConfigs(cluster)
+        require.Error(t, err)
+        require.Contains(t, err.Error(), "Field validation for 'PublicAccessCidrs' failed on the 'required' tag")
+    })
+
+    t.Run("TestReadConfigTreeKeepsTheValidClusters", func(t *testing.T) {
+        rootDir := t.TempDir()
+        writeCluster := func(name, version, subnetId string) {
+            clusterDir := filepath.Join(rootDir, name)
+            require.NoError(t, os.MkdirAll(filepath.Join(clusterDir, "nodegroups"), 0755))
+            require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: `+name+`
+version: "`+version+`"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+            require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "nodegroups", "ng-1.yaml"), []byte(`name: `+name+`-ng-1
+scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  subnetIds:
+    - `+subnetId+`
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+  ec2KeyPair: my-key
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  diskSize: 20
+  instanceTypes:
+    - t3.medium
+tags:
+  key: value
+kubernetesLabels:
+  key: value
+`), 0644))
+        }
+        writeCluster("broken", "1", "subnet-12345678912345678")
+        writeCluster("outside", "1.29", "subnet-0a1b2c3d4e5f6a7b8")
+        writeCluster("valid", "1.29", "subnet-12345678912345678")
+
+        tree, errs := utils.ReadConfigTree(rootDir, options)
+        rules := map[string]string{}
+        for _, validationError := range errs {
+            file, err := filepath.Rel(rootDir, validationError.File)
+            require.NoError(t, err)
+            rules[filepath.ToSlash(file)+"#"+validationError.Path] = validationError.Rule
+        }
+
+        // an invalid cluster does not keep the others from being read and checked against their nodegroups
+        require.Equal(t, "kubernetesversion", rules["broken/config.yaml#version"])
+        require.Equal(t, "clustersubnet", rules["outside/nodegroups/ng-1.yaml#networkConfiguration.subnetIds[0]"])
+        require.Equal(t, []string{"outside", "valid"}, utils.GetClusterNames(tree.Clusters))
+        require.NotContains(t, tree.NodeGroups, "outside")
+        require.Len(t, tree.NodeGroups["valid"], 1)
+        require.Equal(t, "valid-ng-1", tree.NodeGroups["valid"][0].Name)
+        require.Contains(t, tree.FargateProfiles, "valid")
+    })
+
+    t.Run("TestSameNodeGroupNamesAcrossClusters", func(t *testing.T) {
+        rootDir := t.TempDir()
+        for _, name := range []string{"cluster-a", "cluster-b"} {
+            clusterDir := filepath.Join(rootDir, name)
+            require.NoError(t, os.MkdirAll(filepath.Join(clusterDir, "nodegroups"), 0755))
+            require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: `+name+`
+version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+            require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "nodegroups", "workers.yaml"), []byte(`name: ng-1
+scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+  ec2KeyPair: my-key
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  diskSize: 20
+  instanceTypes:
+    - t3.medium
+tags:
+  key: value
+kubernetesLabels:
+  key: value
+`), 0644))
+        }
+
+        // the nodegroup resources are named after their cluster too
+        tree, err := utils.ValidateConfigTree(rootDir, options)
+        require.NoError(t, err)
+        require.Equal(t, "ng-1", tree.NodeGroups["cluster-a"][0].Name)
+        require.Equal(t, "ng-1", tree.NodeGroups["cluster-b"][0].Name)
+    })
+
+    t.Run("TestValidateConfigTreeReportsAllErrors", func(t *testing.T) {
+        rootDir := t.TempDir()
+        clusterDir := filepath.Join(rootDir, "my-cluster")
+        require.NoError(t, os.MkdirAll(filepath.Join(clusterDir, "nodegroups"), 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: my-cluster
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+  - subnet-123
+tags:
+  key: value
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "nodegroups", "ng-1.yaml"), []byte(`name: ng-1
+scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 2
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  instanceTypes:
+    - t3.medium
+tags:
+  key: value
+kubernetesLabels:
+  key: value
+launchTemplate:
+  blockDeviceMappings:
+    - deviceName: /dev/xvda
+      volumeSize: 20
+      volumeType: gp2
+      throughput: 250
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "nodegroups", "ng-2.yaml"), []byte(`name: ng-2
+scalingConfiguration:
+  desiredCapacity: two
+`), 0644))
+
+        _, err := utils.ValidateConfigTree(rootDir, options)
+        require.Error(t, err)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+
+        byPath := map[string]utils.ValidationError{}
+        for _, validationError := range validationErrors {
+            byPath[filepath.Base(validationError.File)+"#"+validationError.Path] = validationError
+        }
+
+        // a missing key points at the root of the document
+        require.Equal(t, utils.ValidationError{File: filepath.Join(clusterDir, "config.yaml"), Line: 1, Column: 1, Path: "version", Rule: "required", Message: "is required"}, byPath["config.yaml#version"])
+        require.Equal(t, 9, byPath["config.yaml#subnetIds[1]"].Line)
+        require.Equal(t, 5, byPath["config.yaml#subnetIds[1]"].Column)
+        require.Contains(t, byPath["config.yaml#subnetIds[1]"].Message, `must be a subnet ID like subnet-0123456789abcdef0, got "subnet-123"`)
+
+        require.Equal(t, 4, byPath["ng-1.yaml#scalingConfiguration.minSize"].Line)
+        require.Equal(t, "must be less than or equal to desiredCapacity, got 2", byPath["ng-1.yaml#scalingConfiguration.minSize"].Message)
+        require.Equal(t, 28, byPath["ng-1.yaml#launchTemplate.blockDeviceMappings[0].throughput"].Line)
+        require.Equal(t, "is only supported by gp3 volumes, not gp2", byPath["ng-1.yaml#launchTemplate.blockDeviceMappings[0].throughput"].Message)
+
+        require.Equal(t, 3, byPath["ng-2.yaml#"].Line)
+        require.Contains(t, byPath["ng-2.yaml#"].Message, "cannot unmarshal !!str `two` into int")
+
+        require.Contains(t, err.Error(), filepath.Join(clusterDir, "nodegroups", "ng-1.yaml")+":4:3: scalingConfiguration.minSize: must be less than or equal to desiredCapacity, got 2")
+    })
+
+    t.Run("TestStrictDecodingRejectsUnknownAndDuplicateKeys", func(t *testing.T) {
+        rootDir := t.TempDir()
+        nodeGroupDir := filepath.Join(rootDir, "my-cluster", "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "ng-1.yaml"), []byte(`name: ng-1
+scalingConfiguration:
+  desiredCapcity: 2
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+  ec2KeyPair: my-key
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  instanceTypes:
+    - t3.medium
+  diskSize: 20
+  diskSize: 30
+tags:
+  key: value
+  key: other
+kubernetesLabels:
+  key: value
+kubernetesTaints:
+  - key: dedicated
+    value: gpu
+    effect: NO_SCHEDULE
+    operator: Equal
+replicas: 3
+`), 0644))
+
+        _, err := utils.ReadNodeConfigs(nodeGroupDir)
+        require.Error(t, err)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+
+        byPath := map[string]utils.ValidationError{}
+        for _, validationError := range validationErrors {
+            byPath[validationError.Path] = validationError
+        }
+
+        typo := byPath["scalingConfiguration.desiredCapcity"]
+        require.Equal(t, "unknown_key", typo.Rule)
+        require.Equal(t, 3, typo.Line)
+        require.Equal(t, 3, typo.Column)
+        require.Equal(t, `unknown key "desiredCapcity", did you mean "desiredCapacity"?`, typo.Message)
+        // the typo also leaves the desired capacity below the minimum size
+        require.Equal(t, "minfield", byPath["scalingConfiguration.desiredCapacity"].Rule)
+
+        require.Equal(t, `unknown key "operator", expected one of: effect, key, value`, byPath["kubernetesTaints[0].operator"].Message)
+        require.Contains(t, byPath["replicas"].Message, `unknown key "replicas", expected one of: computeConfiguration,`)
+
+        require.Equal(t, "duplicate_key", byPath["computeConfiguration.diskSize"].Rule)
+        require.Equal(t, 21, byPath["computeConfiguration.diskSize"].Line)
+        require.Equal(t, `key "diskSize" is already defined at line 20`, byPath["computeConfiguration.diskSize"].Message)
+        require.Equal(t, `key "key" is already defined at line 23`, byPath["tags.key"].Message)
+        require.Len(t, validationErrors, 7)
+    })
+
+    t.Run("TestNodeGroupDefaults", func(t *testing.T) {
+        rootDir := t.TempDir()
+        nodeGroupDir := filepath.Join(rootDir, "my-cluster", "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(rootDir, "defaults.yaml"), []byte(`nodegroups:
+  networkConfiguration:
+    ec2KeyPair: my-key
+    securityGroupIds:
+      - sg-0f3a7d6b8e5c4e5c9
+  computeConfiguration:
+    amiType: AL2_x86_64
+    capacityType: ON_DEMAND
+  tags:
+    team: platform
+    cost-center: "42"
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "_defaults.yaml"), []byte(`computeConfiguration:
+  diskSize: 20
+  instanceTypes:
+    - t3.medium
+tags:
+  cluster: my-cluster
+kubernetesLabels:
+  cluster: my-cluster
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "ng-1.yaml"), []byte(`name: ng-1
+scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c8
+computeConfiguration:
+  instanceTypes:
+    - m5.large
+tags:
+  team: data
+kubernetesLabels: !reset
+  node: ng-1
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "ng-2.yaml"), []byte(`name: ng-2
+scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+  ec2KeyPair: !reset
+computeConfiguration:
+  diskSize: !reset
+kubernetesLabels:
+  node: ng-2
+launchTemplate:
+  keyName: my-key
+`), 0644))
+
+        nodeGroups, err := utils.ReadNodeConfigs(nodeGroupDir)
+        require.NoError(t, err)
+        require.Len(t, nodeGroups, 2)
+
+        // maps merge key by key, lists replace and !reset replaces an inherited mapping
+        first := nodeGroups[0]
+        require.Equal(t, "my-key", first.NetworkConfiguration.Ec2KeyPair)
+        require.Equal(t, []string{"sg-0f3a7d6b8e5c4e5c8"}, first.NetworkConfiguration.SecurityGroupIds)
+        require.Equal(t, "AL2_x86_64", first.ComputeConfiguration.AmiType)
+        require.Equal(t, []string{"m5.large"}, first.ComputeConfiguration.InstanceTypes)
+        require.Equal(t, 20, first.ComputeConfiguration.DiskSize)
+        require.Equal(t, map[string]string{"team": "data", "cost-center": "42", "cluster": "my-cluster"}, first.Tags)
+        require.Equal(t, map[string]string{"node": "ng-1"}, first.KubernetesLabels)
+
+        // !reset clears the inherited values that a launch template excludes
+        second := nodeGroups[1]
+        require.Equal(t, "", second.NetworkConfiguration.Ec2KeyPair)
+        require.Equal(t, 0, second.ComputeConfiguration.DiskSize)
+        require.Equal(t, []string{"sg-0f3a7d6b8e5c4e5c9"}, second.NetworkConfiguration.SecurityGroupIds)
+        require.Equal(t, map[string]string{"cluster": "my-cluster", "node": "ng-2"}, second.KubernetesLabels)
+    })
+
+    t.Run("TestNodeGroupDefaultsErrorsPointAtTheirFile", func(t *testing.T) {
+        rootDir := t.TempDir()
+        nodeGroupDir := filepath.Join(rootDir, "my-cluster", "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(rootDir, "defaults.yaml"), []byte(`nodegroups:
+  networkConfiguration:
+    ec2KeyPair: my-key
+    securityGroupIds:
+      - sg-123
+  tags:
+    team: platform
+clusters: {}
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "ng-1.yaml"), []byte(`name: ng-1
+scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  instanceTypes:
+    - t3.medium
+  diskSize: 20
+`), 0644))
+
+        _, err := utils.ReadNodeConfigs(nodeGroupDir)
+        require.Error(t, err)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+        require.Len(t, validationErrors, 3)
+
+        defaultsFile := filepath.Join(rootDir, "defaults.yaml")
+        require.Equal(t, utils.ValidationError{File: defaultsFile, Line: 8, Column: 1, Path: "clusters", Rule: "unknown_key", Message: `unknown key "clusters", expected one of: nodegroups`}, validationErrors[0])
+        require.Equal(t, defaultsFile, validationErrors[1].File)
+        require.Equal(t, 5, validationErrors[1].Line)
+        require.Equal(t, "networkConfiguration.securityGroupIds[0]", validationErrors[1].Path)
+        // a key missing from every layer points at the nodegroup file
+        require.Equal(t, utils.ValidationError{File: filepath.Join(nodeGroupDir, "ng-1.yaml"), Line: 1, Column: 1, Path: "kubernetesLabels", Rule: "required", Message: "is required"}, validationErrors[2])
+    })
+
+    t.Run("TestNodeGroupTemplates", func(t *testing.T) {
+        rootDir := t.TempDir()
+        nodeGroupDir := filepath.Join(rootDir, "my-cluster", "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "templates"), 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(rootDir, "my-cluster", "config.yaml"), []byte("name: my-cluster\n"), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(rootDir, "templates", "spot-arm.yaml"), []byte(`scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: percentage
+    value: 50
+networkConfiguration:
+  ec2KeyPair: my-key
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_ARM_64
+  capacityType: SPOT
+  diskSize: 20
+  instanceTypes:
+    - {{ .family }}.medium
+    - {{ .family }}.large
+tags:
+  team: {{ .team }}
+kubernetesLabels:
+  node: spot
+{{- if .zone }}
+  zone: {{ .zone }}
+{{- end }}
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "spot.yaml"), []byte(`name: spot
+template: spot-arm
+parameters:
+  family: m7g
+  team: data
+  zone: ap-south-1a
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+scalingConfiguration:
+  maxSize: 5
+`), 0644))
+
+        nodeGroups, err := utils.ReadNodeConfigs(nodeGroupDir)
+        require.NoError(t, err)
+        require.Len(t, nodeGroups, 1)
+        require.Equal(t, []string{"m7g.medium", "m7g.large"}, nodeGroups[0].ComputeConfiguration.InstanceTypes)
+        require.Equal(t, map[string]string{"team": "data"}, nodeGroups[0].Tags)
+        require.Equal(t, map[string]string{"node": "spot", "zone": "ap-south-1a"}, nodeGroups[0].KubernetesLabels)
+        // the nodegroup file overrides the template
+        require.Equal(t, 5, nodeGroups[0].ScalingConfiguration.MaxSize)
+        require.Equal(t, 1, nodeGroups[0].ScalingConfiguration.MinSize)
+
+        rendered, err := utils.RenderConfigTree(rootDir)
+        require.NoError(t, err)
+        require.Len(t, rendered, 1)
+        require.Equal(t, filepath.Join(nodeGroupDir, "spot.yaml"), rendered[0].File)
+        require.Contains(t, string(rendered[0].YAML), "  instanceTypes:\n    - m7g.medium\n    - m7g.large\n")
+        require.Contains(t, string(rendered[0].YAML), "  maxSize: 5\n")
+
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "spot.yaml"), []byte(`name: spot
+template: spot-arm
+parameters:
+  familly: m7g
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "other.yaml"), []byte(`name: other
+template: on-demand
+`), 0644))
+
+        _, err = utils.ReadNodeConfigs(nodeGroupDir)
+        require.Error(t, err)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+        messages := []string{}
+        for _, validationError := range validationErrors {
+            if validationError.Rule == "template" {
+                messages = append(messages, validationError.Error())
+            }
+        }
+        require.Equal(t, []string{
+            filepath.Join(nodeGroupDir, "other.yaml") + `:2:11: template: template "on-demand" not found, expected ` + filepath.Join(rootDir, "templates", "on-demand.yaml"),
+            filepath.Join(nodeGroupDir, "spot.yaml") + `:4:3: parameters: template "spot-arm" requires parameter "family", which is not set`,
+            filepath.Join(nodeGroupDir, "spot.yaml") + `:4:3: parameters: template "spot-arm" requires parameter "team", which is not set`,
+            filepath.Join(nodeGroupDir, "spot.yaml") + `:4:3: parameters: template "spot-arm" requires parameter "zone", which is not set`,
+            filepath.Join(nodeGroupDir, "spot.yaml") + `:4:3: parameters.familly: parameter "familly" is not used by template "spot-arm"`,
+        }, messages)
+    })
+
+    t.Run("TestConfigDiscovery", func(t *testing.T) {
+        rootDir := t.TempDir()
+        options := options
+        options.CheckClusterDirNames = true
+        clusterConfig := `name: my-cluster
+version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`
+        for _, dir := range []string{"my-cluster", "copy-of-my-cluster", ".my-cluster", "_my-cluster"} {
+            require.NoError(t, os.MkdirAll(filepath.Join(rootDir, dir), 0755))
+            require.NoError(t, os.WriteFile(filepath.Join(rootDir, dir, "config.yaml"), []byte(clusterConfig), 0644))
+        }
+
+        // the hidden and _ prefixed directories are skipped, the other two define the same cluster
+        _, err := utils.ReadClusterConfigs(rootDir, options)
+        require.Error(t, err)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+        require.Equal(t, utils.ValidationErrors{{
+            File:    filepath.Join(rootDir, "copy-of-my-cluster", "config.yaml"),
+            Line:    1,
+            Column:  1,
+            Path:    "name",
+            Rule:    "directory_name",
+            Message: `cluster name "my-cluster" must match its directory "copy-of-my-cluster"`,
+        }, {
+            File:    filepath.Join(rootDir, "my-cluster", "config.yaml"),
+            Line:    1,
+            Column:  1,
+            Path:    "name",
+            Rule:    "duplicate_name",
+            Message: `cluster name "my-cluster" is already defined in ` + filepath.Join(rootDir, "copy-of-my-cluster", "config.yaml"),
+        }}, validationErrors)
+
+        require.NoError(t, os.RemoveAll(filepath.Join(rootDir, "copy-of-my-cluster")))
+        clusterConfigs, err := utils.ReadClusterConfigs(rootDir, options)
+        require.NoError(t, err)
+        require.Equal(t, []string{"my-cluster"}, utils.GetClusterNames(clusterConfigs))
+
+        nodeGroupDir := filepath.Join(rootDir, "my-cluster", "nodegroups")
+        require.NoError(t, os.MkdirAll(filepath.Join(nodeGroupDir, "archive"), 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "_defaults.yaml"), []byte(`scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  ec2KeyPair: my-key
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  instanceTypes:
+    - t3.medium
+  diskSize: 20
+tags:
+  key: value
+kubernetesLabels:
+  key: value
+`), 0644))
+        for file, name := range map[string]string{
+            "spot.yaml":        "spot",
+            "on-demand.yml":    "on-demand",
+            ".spot.yaml.swp":   "swap",
+            ".spot.yaml":       "hidden",
+            "_draft.yaml":      "draft",
+            "spot.yaml~":       "backup",
+            "archive/old.yaml": "old",
+        } {
+            require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, file), []byte("name: "+name+"\n"), 0644))
+        }
+
+        // only the yaml files directly inside the directory are nodegroups, sorted by file name
+        nodeGroups, err := utils.ReadNodeConfigs(nodeGroupDir)
+        require.NoError(t, err)
+        names := []string{}
+        for _, nodeGroup := range nodeGroups {
+            names = append(names, nodeGroup.Name)
+        }
+        require.Equal(t, []string{"on-demand", "spot"}, names)
+
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "web-spot.yaml"), []byte("tags:\n  team: sre\nname: spot\n"), 0644))
+        _, err = utils.ReadNodeConfigs(nodeGroupDir)
+        require.Error(t, err)
+        require.True(t, errors.As(err, &validationErrors))
+        require.Len(t, validationErrors, 1)
+        require.Equal(t, filepath.Join(nodeGroupDir, "web-spot.yaml")+`:3:1: name: nodegroup name "spot" is already defined in `+filepath.Join(nodeGroupDir, "spot.yaml"), validationErrors[0].Error())
+    })
+
+    t.Run("TestClusterNameMatchesDirectory", func(t *testing.T) {
+        rootDir := t.TempDir()
+        options := options
+        options.CheckClusterDirNames = true
+        clusterConfig := `version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`
+        longName := strings.Repeat("a", 101)
+        for dir, name := range map[string]string{
+            "my-cluster": "my-cluster",
+            "renamed":    "my-other-cluster",
+            "space":      `"space "`,
+            "-dash":      "-dash",
+            longName:     longName,
+        } {
+            require.NoError(t, os.MkdirAll(filepath.Join(rootDir, dir), 0755))
+            require.NoError(t, os.WriteFile(filepath.Join(rootDir, dir, "config.yaml"), []byte(clusterConfig+"name: "+name+"\n"), 0644))
+        }
+
+        _, err := utils.ReadClusterConfigs(rootDir, options)
+        require.Error(t, err)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+        messages := []string{}
+        for _, validationError := range validationErrors {
+            messages = append(messages, filepath.Base(filepath.Dir(validationError.File))+": "+validationError.Rule+": "+validationError.Message)
+        }
+        require.Equal(t, []string{
+            `-dash: clustername: must start with a letter or digit and contain only letters, digits, hyphens and underscores, got "-dash"`,
+            longName + ": max: must be at most 100 characters long",
+            `renamed: directory_name: cluster name "my-other-cluster" must match its directory "renamed"`,
+            `space: clustername: must start with a letter or digit and contain only letters, digits, hyphens and underscores, got "space "`,
+            `space: directory_name: cluster name "space " must match its directory "space"`,
+        }, messages)
+
+        // the directory names are only checked when asked for
+        options.CheckClusterDirNames = false
+        for _, dir := range []string{"space", "-dash", longName} {
+            require.NoError(t, os.RemoveAll(filepath.Join(rootDir, dir)))
+        }
+        clusterConfigs, err := utils.ReadClusterConfigs(rootDir, options)
+        require.NoError(t, err)
+        require.Equal(t, filepath.Join(rootDir, "my-cluster"), clusterConfigs["my-cluster"].Dir)
+        require.Equal(t, filepath.Join(rootDir, "renamed"), clusterConfigs["my-other-cluster"].Dir)
+    })
+
+    t.Run("TestTargetConfig", func(t *testing.T) {
+        targets, err := utils.NewTargetConfig(` dev-*, stage-* `, `["*-legacy"]`, "")
+        require.NoError(t, err)
+        require.Equal(t, utils.TargetConfig{IncludeClusters: []string{"dev-*", "stage-*"}, ExcludeClusters: []string{"*-legacy"}}, targets)
+        require.False(t, targets.IsEmpty())
+
+        require.Equal(t, "", targets.GetClusterExclusion("dev-payments"))
+        require.Equal(t, "not matched by include-clusters", targets.GetClusterExclusion("prod-payments"))
+        require.Equal(t, `matched by exclude-clusters pattern "*-legacy"`, targets.GetClusterExclusion("dev-legacy"))
+
+        targets, err = utils.NewTargetConfig("", "", "spot-*,payments/on-demand")
+        require.NoError(t, err)
+        require.Equal(t, "", targets.GetNodeGroupExclusion("orders", "spot-arm"))
+        require.Equal(t, "", targets.GetNodeGroupExclusion("payments", "on-demand"))
+        require.Equal(t, "not matched by include-nodegroups", targets.GetNodeGroupExclusion("orders", "on-demand"))
+
+        clusterConfigs := map[string]utils.ClusterConfig{"dev-a": {Name: "dev-a"}, "dev-b": {Name: "dev-b"}, "prod": {Name: "prod"}}
+        targets = utils.TargetConfig{ExcludeClusters: []string{"dev-*"}}
+        notFound := func(string) (bool, error) { return false, nil }
+        selected, unchanged, err := targets.SelectClusters(clusterConfigs, map[string]bool{"dev-a": true, "prod": true}, notFound)
+        require.NoError(t, err)
+        require.Equal(t, []string{"dev-a", "prod"}, utils.GetClusterNames(selected))
+        require.Equal(t, map[string]bool{"dev-a": true}, unchanged)
+
+        // an excluded cluster that exists without being deployed can not be skipped safely
+        _, _, err = targets.SelectClusters(clusterConfigs, map[string]bool{"prod": true}, func(name string) (bool, error) { return name == "dev-a", nil })
+        require.ErrorContains(t, err, "cluster dev-a is excluded and missing from the outputs of the stack although it exists")
+        _, _, err = targets.SelectClusters(clusterConfigs, map[string]bool{"prod": true}, func(string) (bool, error) { return false, errors.New("access denied") })
+        require.EqualError(t, err, "cluster dev-a is excluded and missing from the outputs of the stack, failed to check whether it exists: access denied")
+
+        nodeGroupConfigs := []utils.NodeGroupConfig{{Name: "on-demand"}, {Name: "spot"}, {Name: "gpu"}}
+        targets = utils.TargetConfig{IncludeNodeGroups: []string{"on-demand"}}
+        selectedNodeGroups, unchanged, err := targets.SelectNodeGroups("dev-a", false, nodeGroupConfigs, map[string]bool{"dev-a/spot": true, "prod/gpu": true}, notFound)
+        require.NoError(t, err)
+        require.Equal(t, []utils.NodeGroupConfig{{Name: "on-demand"}, {Name: "spot"}}, selectedNodeGroups)
+        require.Equal(t, map[string]bool{"spot": true}, unchanged)
+
+        // the nodegroups of an unchanged cluster are all excluded
+        selectedNodeGroups, unchanged, err = utils.TargetConfig{}.SelectNodeGroups("dev-a", true, nodeGroupConfigs, map[string]bool{"dev-a/spot": true}, notFound)
+        require.NoError(t, err)
+        require.Equal(t, []utils.NodeGroupConfig{{Name: "spot"}}, selectedNodeGroups)
+        require.Equal(t, map[string]bool{"spot": true}, unchanged)
+
+        _, _, err = targets.SelectNodeGroups("dev-a", false, nodeGroupConfigs, map[string]bool{"dev-a/retired": true}, notFound)
+        require.EqualError(t, err, "nodegroup dev-a/retired is deployed but its file was removed, it would be deleted although it is excluded: not matched by include-nodegroups")
+        _, _, err = utils.TargetConfig{}.SelectNodeGroups("dev-a", false, nodeGroupConfigs, map[string]bool{"dev-a/retired": true}, notFound)
+        require.NoError(t, err)
+
+        _, err = utils.NewTargetConfig("dev-[", "", "")
+        require.EqualError(t, err, `include-clusters: invalid glob pattern "dev-[": syntax error in pattern`)
+        _, err = utils.NewTargetConfig("", `["dev"`, "")
+        require.ErrorContains(t, err, "exclude-clusters must be a list of glob patterns")
+    })
+
+    t.Run("TestServiceIpv4CidrRules", func(t *testing.T) {
+        for _, test := range []struct {
+            serviceIpv4Cidr string
+            extra           string
+            messages        []string
+        }{
+            {"172.20.0.0/16", "vpcCidrs: [10.0.0.0/16]\npodCidrs: [100.64.0.0/16]\n", nil},
+            {"10.100.0.0/24", "", nil},
+            {"172.16.0.0/12", "", nil},
+            {"100.64.0.0/16", "", []string{`must be within one of the private blocks 10.0.0.0/8, 172.16.0.0/12 or 192.168.0.0/16, got "100.64.0.0/16"`}},
+            {"172.0.0.0/11", "", []string{
+                `must have a prefix length between /12 and /24, got "172.0.0.0/11" with /11`,
+                `must be within one of the private blocks 10.0.0.0/8, 172.16.0.0/12 or 192.168.0.0/16, got "172.0.0.0/11"`,
+            }},
+            {"10.0.0.0/25", "", []string{`must have a prefix length between /12 and /24, got "10.0.0.0/25" with /25`}},
+            {"10.100.0.0/16", "vpcCidrs: [10.0.0.0/8, 172.31.0.0/16]\npodCidrs: [10.100.128.0/17]\n", []string{
+                `must not overlap the VPC CIDR 10.0.0.0/8, got "10.100.0.0/16"`,
+                `must not overlap the pod CIDR 10.100.128.0/17, got "10.100.0.0/16"`,
+            }},
+        } {
+            rootDir := t.TempDir()
+            require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "my-cluster"), 0755))
+            require.NoError(t, os.WriteFile(filepath.Join(rootDir, "my-cluster", "config.yaml"), []byte(`name: my-cluster
+version: "1.29"
+serviceIpv4Cidr: `+test.serviceIpv4Cidr+`
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`+test.extra), 0644))
+
+            _, err := utils.ReadClusterConfigs(rootDir, options)
+            if test.messages == nil {
+                require.NoError(t, err, test.serviceIpv4Cidr)
+                continue
+            }
+            var validationErrors utils.ValidationErrors
+            require.True(t, errors.As(err, &validationErrors), test.serviceIpv4Cidr)
+            messages := []string{}
+            for _, validationError := range validationErrors {
+                require.Equal(t, "serviceIpv4Cidr", validationError.Path)
+                require.Equal(t, 3, validationError.Line)
+                messages = append(messages, validationError.Message)
+            }
+            require.Equal(t, test.messages, messages)
+        }
+    })
+
+    t.Run("TestClusterNodeGroupConsistency", func(t *testing.T) {
+        rootDir := t.TempDir()
+        clusterDir := filepath.Join(rootDir, "my-cluster")
+        nodeGroupDir := filepath.Join(clusterDir, "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: my-cluster
+version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "_defaults.yaml"), []byte(`scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  ec2KeyPair: my-key
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  instanceTypes:
+    - t3.medium
+  diskSize: 20
+tags:
+  key: value
+kubernetesLabels:
+  node.kubernetes.io/role: worker
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "ng-1.yaml"), []byte(`name: ng-1
+networkConfiguration:
+  subnetIds:
+    - subnet-12345678912345678
+    - subnet-87654321987654321
+kubernetesLabels:
+  team: payments
+  example.com/tier: "-backend"
+  Example.com/zone: a
+  eks.amazonaws.com/capacityType: SPOT
+kubernetesTaints:
+  - key: dedicated
+    value: payments
+    effect: NO_SCHEDULE
+  - key: dedicated
+    value: other
+    effect: NO_SCHEDULE
+  - key: dedicated
+    value: payments
+    effect: NO_EXECUTE
+  - key: -dedicated
+    value: "payments team"
+    effect: NO_EXECUTE
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "ng-2.yaml"), []byte(`name: ng-2
+networkConfiguration:
+  subnetIds:
+    - subnet-87654321987654321
+  allowSubnetsOutsideCluster: true
+kubernetesLabels:
+  team: payments
+`), 0644))
+
+        // the label inherited by both nodegroups is reported once, ng-2 may use a subnet outside the cluster
+        _, err := utils.ValidateConfigTree(rootDir, options)
+        require.Error(t, err)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+        lines := []string{}
+        for _, validationError := range validationErrors {
+            lines = append(lines, strings.TrimPrefix(validationError.Error(), nodeGroupDir+string(filepath.Separator)))
+        }
+        require.Equal(t, []string{
+            `ng-1.yaml:5:7: networkConfiguration.subnetIds[1]: subnet subnet-87654321987654321 is not one of the subnetIds of cluster my-cluster, set allowSubnetsOutsideCluster to use it anyway`,
+            `ng-1.yaml:9:3: kubernetesLabels.Example.com/zone: key "Example.com/zone" must have a prefix that is a lowercase DNS subdomain of at most 253 characters`,
+            `ng-1.yaml:10:3: kubernetesLabels.eks.amazonaws.com/capacityType: label "eks.amazonaws.com/capacityType" uses the prefix of eks.amazonaws.com, which is reserved for Kubernetes and EKS`,
+            `ng-1.yaml:8:3: kubernetesLabels.example.com/tier: value "-backend" must be at most 63 letters, digits, '-', '_' or '.', starting and ending with a letter or digit`,
+            `_defaults.yaml:21:3: kubernetesLabels.node.kubernetes.io/role: label "node.kubernetes.io/role" uses the prefix of kubernetes.io, which is reserved for Kubernetes and EKS`,
+            `ng-1.yaml:15:5: kubernetesTaints[1]: taint dedicated with effect NO_SCHEDULE is already defined by kubernetesTaints[0]`,
+            `ng-1.yaml:21:5: kubernetesTaints[3].key: key "-dedicated" must have a name of at most 63 letters, digits, '-', '_' or '.', starting and ending with a letter or digit`,
+            `ng-1.yaml:22:5: kubernetesTaints[3].value: value "payments team" must be at most 63 letters, digits, '-', '_' or '.', starting and ending with a letter or digit`,
+        }, lines)
+    })
+
+    t.Run("TestInstanceTypeCatalog", func(t *testing.T) {
+        family, ok := utils.LookupInstanceFamily("m6g.large")
+        require.True(t, ok)
+        require.Equal(t, "arm64", family.Architecture)
+        require.True(t, family.HasSize("m6g.large"))
+        require.False(t, family.HasSize("m6g.huge"))
+        _, ok = utils.LookupInstanceFamily("x9z.large")
+        require.False(t, ok)
+
+        rootDir := t.TempDir()
+        clusterDir := filepath.Join(rootDir, "my-cluster")
+        nodeGroupDir := filepath.Join(clusterDir, "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: my-cluster
+version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "_defaults.yaml"), []byte(`scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  ec2KeyPair: my-key
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  diskSize: 20
+tags:
+  key: value
+kubernetesLabels:
+  team: payments
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "arm.yaml"), []byte(`name: arm
+computeConfiguration:
+  instanceTypes:
+    - m5.large
+    - m6g.large
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "gpu.yaml"), []byte(`name: gpu
+computeConfiguration:
+  amiType: AL2_x86_64_GPU
+  instanceTypes:
+    - g5.xlarge
+    - m5.large
+    - inf2.xlarge
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "nvidia.yaml"), []byte(`name: nvidia
+computeConfiguration:
+  amiType: BOTTLEROCKET_x86_64_NVIDIA
+  instanceTypes:
+    - t3.large
+    - inf2.xlarge
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "old.yaml"), []byte(`name: old
+computeConfiguration:
+  instanceTypes:
+    - m3.medium
+    - x9z.large
+    - mac1.metal
+`), 0644))
+
+        lines := []string{}
+        for _, validationError := range utils.CheckConfigTree(rootDir, options) {
+            lines = append(lines, strings.TrimPrefix(validationError.Error(), nodeGroupDir+string(filepath.Separator)))
+        }
+        require.Equal(t, []string{
+            `arm.yaml:5:7: computeConfiguration.instanceTypes[1]: instance type m6g.large is arm64 while amiType AL2_x86_64 is x86_64`,
+            `gpu.yaml:6:7: computeConfiguration.instanceTypes[1]: amiType AL2_x86_64_GPU needs instance types with nvidia or neuron accelerators, m5.large has none`,
+            `nvidia.yaml:5:7: computeConfiguration.instanceTypes[0]: amiType BOTTLEROCKET_x86_64_NVIDIA needs instance types with nvidia accelerators, t3.large has none`,
+            `nvidia.yaml:6:7: computeConfiguration.instanceTypes[1]: amiType BOTTLEROCKET_x86_64_NVIDIA needs instance types with nvidia accelerators, inf2.xlarge has none`,
+            `old.yaml:4:7: computeConfiguration.instanceTypes[0]: warning: instance type m3.medium is of a previous generation, a current generation family is usually cheaper and faster`,
+            `old.yaml:5:7: computeConfiguration.instanceTypes[1]: warning: instance type x9z.large is not in the instance type catalog, its architecture and accelerators are not checked`,
+            `old.yaml:6:7: computeConfiguration.instanceTypes[2]: instance type mac1.metal is not supported by EKS managed nodegroups`,
+        }, lines)
+
+        // the warnings are logged without failing the validation
+        _, err := utils.ValidateConfigTree(rootDir, options)
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+        require.Len(t, validationErrors, 5)
+
+        // without an AMI fixing the architecture the instance types have to agree on one
+        err = utils.ValidateClusterNodeGroups(utils.ClusterConfig{Name: "my-cluster"}, []utils.NodeGroupConfig{{
+            Name: "custom",
+            ComputeConfiguration: utils.ComputeConfig{AmiType: "CUSTOM", InstanceTypes: []string{"c6g.large", "c7g.large", "c6i.large"}},
+        }})
+        require.True(t, errors.As(err, &validationErrors))
+        require.Len(t, validationErrors, 1)
+        require.Equal(t, "instancetype_arch", validationErrors[0].Rule)
+        require.Equal(t, "computeConfiguration.instanceTypes[2]", validationErrors[0].Path)
+        require.Equal(t, "instance type c6i.large is x86_64 while c6g.large is arm64, the instance types of a nodegroup must share an architecture", validationErrors[0].Message)
+    })
+
+    t.Run("TestEnumNormalization", func(t *testing.T) {
+        rootDir := t.TempDir()
+        clusterDir := filepath.Join(rootDir, "my-cluster")
+        nodeGroupDir := filepath.Join(clusterDir, "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: my-cluster
+version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "_defaults.yaml"), []byte(`scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+networkConfiguration:
+  ec2KeyPair: my-key
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  instanceTypes:
+    - t3.medium
+  diskSize: 20
+tags:
+  key: value
+kubernetesLabels:
+  team: payments
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "lower.yaml"), []byte(`name: lower
+scalingConfiguration:
+  maximumUnavailable:
+    type: Percentage
+    value: 50
+computeConfiguration:
+  amiType: al2_x86_64
+  capacityType: spot
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "ranges.yaml"), []byte(`name: ranges
+scalingConfiguration:
+  maximumUnavailable:
+    type: percentage
+    value: 150
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "unknown.yaml"), []byte(`name: unknown
+scalingConfiguration:
+  maximumUnavailable:
+    type: percent
+    value: 5
+computeConfiguration:
+  capacityType: RESERVED
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "wide.yaml"), []byte(`name: wide
+scalingConfiguration:
+  maximumUnavailable:
+    type: number
+    value: 5
+`), 0644))
+
+        lines := []string{}
+        for _, validationError := range utils.CheckConfigTree(rootDir, options) {
+            lines = append(lines, strings.TrimPrefix(validationError.Error(), nodeGroupDir+string(filepath.Separator)))
+        }
+        require.Equal(t, []string{
+            `lower.yaml:4:5: scalingConfiguration.maximumUnavailable.type: warning: "Percentage" is deprecated, write "percentage" as EKS values are case sensitive`,
+            `lower.yaml:7:3: computeConfiguration.amiType: warning: "al2_x86_64" is deprecated, write "AL2_x86_64" as EKS values are case sensitive`,
+            `lower.yaml:8:3: computeConfiguration.capacityType: warning: "spot" is deprecated, write "SPOT" as EKS values are case sensitive`,
+            `ranges.yaml:5:5: scalingConfiguration.maximumUnavailable.value: must be between 1 and 100 for a percentage, got 150`,
+            `unknown.yaml:4:5: scalingConfiguration.maximumUnavailable.type: must be one of number, percentage, got "percent"`,
+            `unknown.yaml:7:3: computeConfiguration.capacityType: must be one of ON_DEMAND, SPOT, CAPACITY_BLOCK, got "RESERVED"`,
+            `wide.yaml:5:5: scalingConfiguration.maximumUnavailable.value: must be between 1 and maxSize 3 for a number, got 5`,
+        }, lines)
+
+        // the values are passed to EKS in the case it expects
+        require.NoError(t, os.Remove(filepath.Join(nodeGroupDir, "ranges.yaml")))
+        require.NoError(t, os.Remove(filepath.Join(nodeGroupDir, "unknown.yaml")))
+        require.NoError(t, os.Remove(filepath.Join(nodeGroupDir, "wide.yaml")))
+        nodeGroups, err := utils.ReadNodeConfigs(nodeGroupDir)
+        require.NoError(t, err)
+        require.Len(t, nodeGroups, 1)
+        require.Equal(t, "AL2_x86_64", nodeGroups[0].ComputeConfiguration.AmiType)
+        require.Equal(t, "SPOT", nodeGroups[0].ComputeConfiguration.CapacityType)
+        require.Equal(t, "percentage", nodeGroups[0].ScalingConfiguration.MaximumUnavailable.Type)
+    })
+
+    t.Run("TestKubernetesVersionPolicy", func(t *testing.T) {
+        rootDir := t.TempDir()
+        writeCluster := func(name, version string) {
+            require.NoError(t, os.MkdirAll(filepath.Join(rootDir, name), 0755))
+            require.NoError(t, os.WriteFile(filepath.Join(rootDir, name, "config.yaml"), []byte(`name: `+name+`
+version: "`+version+`"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+        }
+
+        // only the versions of the EKS version list are accepted, older and newer ones are rejected
+        for _, version := range []string{"1.18", "1.40"} {
+            writeCluster("unlisted", version)
+            _, err := utils.ValidateConfigTree(rootDir, options)
+            require.ErrorContains(t, err, filepath.Join(rootDir, "unlisted", "config.yaml")+`:2:1: version: must be one of the EKS versions 1.23 to 1.34 listed in eks-versions.json, got "`+version+`"`)
+        }
+        require.NoError(t, os.RemoveAll(filepath.Join(rootDir, "unlisted")))
+
+        // the end of standard support is warned about, a version past the end of extended support is rejected
+        writeCluster("current", "1.29")
+        writeCluster("next", "1.34")
+        getProblems := func(date time.Time) []string {
+            options := options
+            options.SupportPolicy.Now = func() time.Time { return date }
+            problems := []string{}
+            for _, validationError := range utils.CheckConfigTree(rootDir, options) {
+                problems = append(problems, strings.TrimPrefix(validationError.Error(), rootDir+string(filepath.Separator)))
+            }
+            return problems
+        }
+        require.Equal(t, []string{}, getProblems(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+        require.Equal(t, []string{
+            filepath.Join("current", "config.yaml") + `:2:1: version: warning: version 1.29 reaches the end of standard support on 2025-03-23, in 81 day(s)`,
+        }, getProblems(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+        require.Equal(t, []string{
+            filepath.Join("current", "config.yaml") + `:2:1: version: warning: version 1.29 reached the end of standard support on 2025-03-23, the cluster is billed for extended support until 2026-03-23`,
+        }, getProblems(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)))
+        require.Equal(t, []string{
+            filepath.Join("current", "config.yaml") + `:2:1: version: version 1.29 reached the end of extended support on 2026-03-23, EKS no longer supports it, upgrade to 1.30 or newer`,
+        }, getProblems(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)))
+
+        // the control plane is upgraded one minor version at a time and never downgraded
+        clusterConfigs, err := utils.ReadClusterConfigs(rootDir, options)
+        require.NoError(t, err)
+        require.NoError(t, utils.CheckVersionUpgrades(clusterConfigs, map[string]string{"current": "1.28", "next": "1.33"}))
+        require.NoError(t, utils.CheckVersionUpgrades(clusterConfigs, map[string]string{"current": "1.29"}))
+        err = utils.CheckVersionUpgrades(clusterConfigs, map[string]string{"current": "1.27", "next": "1.35"})
+        var validationErrors utils.ValidationErrors
+        require.True(t, errors.As(err, &validationErrors))
+        require.Equal(t, []string{
+            filepath.Join(rootDir, "current", "config.yaml") + `:2:1: version: version 1.29 is more than one minor version above the deployed version 1.27, upgrade to 1.28 first`,
+            filepath.Join(rootDir, "next", "config.yaml") + `:2:1: version: version 1.34 is older than the deployed version 1.35, EKS can not downgrade a cluster`,
+        }, []string{validationErrors[0].Error(), validationErrors[1].Error()})
+
+        versions, err := utils.ReadClusterVersions(rootDir)
+        require.NoError(t, err)
+        require.Equal(t, map[string]string{"current": "1.29", "next": "1.34"}, versions)
+    })
+
+    t.Run("TestNodeGroupVersions", func(t *testing.T) {
+        rootDir := t.TempDir()
+        clusterDir := filepath.Join(rootDir, "my-cluster")
+        nodeGroupDir := filepath.Join(clusterDir, "nodegroups")
+        require.NoError(t, os.MkdirAll(nodeGroupDir, 0755))
+        require.NoError(t, os.WriteFile(filepath.Join(clusterDir, "config.yaml"), []byte(`name: my-cluster
+version: "1.29"
+serviceIpv4Cidr: 172.20.0.0/16
+publicAccessCidrs:
+  - 10.0.0.0/16
+securityGroupIds:
+  - sg-0f3a7d6b8e5c4e5c9
+subnetIds:
+  - subnet-12345678912345678
+tags:
+  key: value
+`), 0644))
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "_defaults.yaml"), []byte(`scalingConfiguration:
+  desiredCapacity: 1
+  minSize: 1
+  maxSize: 3
+  maximumUnavailable:
+    type: number
+    value: 1
+networkConfiguration:
+  ec2KeyPair: my-key
+  subnetIds:
+    - subnet-12345678912345678
+  securityGroupIds:
+    - sg-0f3a7d6b8e5c4e5c9
+computeConfiguration:
+  amiType: AL2_x86_64
+  capacityType: ON_DEMAND
+  diskSize: 20
+  instanceTypes:
+    - m5.large
+tags:
+  key: value
+kubernetesLabels:
+  team: payments
+`), 0644))
+        for name, computeConfiguration := range map[string]string{
+            "pinned":   "  version: \"1.27\"\n  releaseVersion: 1.27.9-20240514\n  forceUpdateVersion: true\n",
+            "latest":   "  releaseVersion: 1.29.3-20240514\n",
+            "newer":    "  version: \"1.30\"\n",
+            "skewed":   "  version: \"1.25\"\n",
+            "release":  "  releaseVersion: 1.28.5-20240514\n",
+            "mismatch": "  version: \"1.28\"\n  releaseVersion: 1.29.3-20240514\n",
+            "invalid":  "  releaseVersion: latest\n",
+        } {
+            require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, name+".yaml"), []byte("name: "+name+"\ncomputeConfiguration:\n"+computeConfiguration), 0644))
+        }
+        // a custom AMI brings its own version
+        require.NoError(t, os.WriteFile(filepath.Join(nodeGroupDir, "custom.yaml"), []byte(`name: custom
+networkConfiguration:
+  ec2KeyPair: !reset
+computeConfiguration:
+  amiType: CUSTOM
+  diskSize: !reset
+  version: "1.29"
+launchTemplate:
+  imageId: ami-0123456789abcdef0
+  userData: |
+    #!/bin/bash
+    /etc/eks/bootstrap.sh my-cluster
+`), 0644))
+
+        getLines := func() []string {
+            lines := []string{}
+            for _, validationError := range utils.CheckConfigTree(rootDir, options) {
+                lines = append(lines, strings.TrimPrefix(validationError.Error(), nodeGroupDir+string(filepath.Separator)))
+            }
+            return lines
+        }
+        require.Equal(t, []string{
+            `custom.yaml:7:3: computeConfiguration.version: must not be set when amiType is CUSTOM, the version comes from the AMI`,
+            `invalid.yaml:3:3: computeConfiguration.releaseVersion: must be an EKS AMI release version like 1.29.3-20240514, got "latest"`,
+            `mismatch.yaml:4:3: computeConfiguration.releaseVersion: must be a release of version 1.28, got "1.29.3-20240514"`,
+        }, getLines())
+
+        // the nodegroups are checked against the cluster version once they are valid on their own
+        for _, name := range []string{"custom", "invalid", "mismatch"} {
+            require.NoError(t, os.Remove(filepath.Join(nodeGroupDir, name+".yaml")))
+        }
+        require.Equal(t, []string{
+            `newer.yaml:3:3: computeConfiguration.version: version 1.30 is newer than the version 1.29 of cluster my-cluster, upgrade the cluster first`,
+            `release.yaml:3:3: computeConfiguration.releaseVersion: release 1.28.5-20240514 is not a release of the version 1.29 of cluster my-cluster, set version to pin the nodegroup to another version`,
+            `skewed.yaml:3:3: computeConfiguration.version: version 1.25 is more than 3 minor versions older than the version 1.29 of cluster my-cluster`,
+        }, getLines())
+
+    })
 }
@@ -0,0 +1,621 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// ValidationError is a single problem of a config file, located by the YAML key path and position
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string // YAML key path like "scalingConfiguration.minSize"
+	Rule    string // validation tag that failed, "yaml" for files that can not be unmarshalled
+	Message string
+	Warning bool // reported without failing the validation
+}
+
+func (e ValidationError) Error() string {
+	var builder strings.Builder
+	builder.WriteString(e.File)
+	if e.Line > 0 {
+		builder.WriteString(fmt.Sprintf(":%d:%d", e.Line, e.Column))
+	}
+	builder.WriteString(": ")
+	if e.Path != "" {
+		builder.WriteString(e.Path + ": ")
+	}
+	if e.Warning {
+		builder.WriteString("warning: ")
+	}
+	builder.WriteString(e.Message)
+	return builder.String()
+}
+
+// ValidationErrors collects every problem found in the config tree so that they are reported at once
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("found %d config validation error(s):", len(e)))
+	for _, validationError := range e {
+		lines = append(lines, "  "+validationError.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Split returns the errors and the warnings of e
+func (e ValidationErrors) Split() (ValidationErrors, ValidationErrors) {
+	var errs, warnings ValidationErrors
+	for _, validationError := range e {
+		if validationError.Warning {
+			warnings = append(warnings, validationError)
+		} else {
+			errs = append(errs, validationError)
+		}
+	}
+	return errs, warnings
+}
+
+// appendValidationErrors adds the problems of err to errs, errors other than ValidationErrors are attributed to file
+func appendValidationErrors(errs ValidationErrors, file string, err error) ValidationErrors {
+	if err == nil {
+		return errs
+	}
+	var validationErrors ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return append(errs, validationErrors...)
+	}
+	return append(errs, ValidationError{File: file, Message: err.Error()})
+}
+
+// configDocument is the YAML of a config, merged from one or more files, with the file each node was read from
+type configDocument struct {
+	Path    string // the config file, reported for the nodes without a source
+	Root    *yamlv3.Node
+	Sources map[*yamlv3.Node]string
+}
+
+func (d *configDocument) getFile(node *yamlv3.Node) string {
+	if file, ok := d.Sources[node]; ok {
+		return file
+	}
+	return d.Path
+}
+
+// getFileOfLine returns the file of the first node on line, the YAML errors only report line numbers
+func (d *configDocument) getFileOfLine(line int) string {
+	var file string
+	var walk func(node *yamlv3.Node)
+	walk = func(node *yamlv3.Node) {
+		if file != "" {
+			return
+		}
+		if node.Line == line {
+			file = d.getFile(node)
+			return
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	if d.Root != nil {
+		walk(d.Root)
+	}
+	if file == "" {
+		return d.Path
+	}
+	return file
+}
+
+// readConfigDocument parses a YAML file, an empty file is an empty mapping
+func readConfigDocument(path string) (*configDocument, ValidationErrors) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, ValidationErrors{{File: path, Message: err.Error()}}
+	}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &document); err != nil {
+		return nil, getUnmarshalErrors(&configDocument{Path: path}, err)
+	}
+
+	root := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map", Line: 1, Column: 1}
+	if len(document.Content) > 0 {
+		root = document.Content[0]
+	}
+	return &configDocument{Path: path, Root: root}, nil
+}
+
+// decodeConfigFile strictly reads, unmarshals and validates a single config file into config
+func decodeConfigFile(path string, config interface{}) (*configDocument, ValidationErrors) {
+	document, errs := readConfigDocument(path)
+	if document == nil {
+		return nil, errs
+	}
+
+	// unknown and duplicate keys are reported next to the validation errors of the config
+	errs = checkYamlKeys(document, reflect.TypeOf(config))
+
+	return document, append(errs, decodeConfigDocument(mergeConfigDocuments(document), config)...)
+}
+
+// enumNormalization is a value of a config rewritten to the case EKS expects
+type enumNormalization struct {
+	KeyPath    []yamlKey
+	Value      string
+	Normalized string
+}
+
+// enumNormalizer is a config with values that are accepted in any case, with a warning, and rewritten before validation
+type enumNormalizer interface {
+	normalizeEnums() []enumNormalization
+}
+
+// decodeConfigDocument unmarshals and validates a resolved document into config, the warnings of the
+// normalized values are returned along with the errors
+func decodeConfigDocument(document *configDocument, config interface{}) ValidationErrors {
+	err := document.Root.Decode(config)
+	if err != nil {
+		return getUnmarshalErrors(document, err)
+	}
+
+	var warnings ValidationErrors
+	if normalizer, ok := config.(enumNormalizer); ok {
+		for _, normalization := range normalizer.normalizeEnums() {
+			node := findYamlNode(document.Root, normalization.KeyPath)
+			warnings = append(warnings, ValidationError{
+				File:    document.getFile(node),
+				Line:    node.Line,
+				Column:  node.Column,
+				Path:    formatYamlKeyPath(normalization.KeyPath),
+				Rule:    "enum_case",
+				Message: fmt.Sprintf("%q is deprecated, write %q as EKS values are case sensitive", normalization.Value, normalization.Normalized),
+				Warning: true,
+			})
+		}
+	}
+
+	err = ValidateConfigs(config)
+	if err != nil {
+		return append(warnings, getValidationErrors(document, config, err)...)
+	}
+
+	return warnings
+}
+
+var unmarshalErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// getUnmarshalErrors splits a YAML error into one entry per line it reports
+func getUnmarshalErrors(document *configDocument, err error) ValidationErrors {
+	messages := []string{err.Error()}
+	var typeError *yamlv3.TypeError
+	if errors.As(err, &typeError) {
+		messages = typeError.Errors
+	}
+
+	var result ValidationErrors
+	for _, message := range messages {
+		validationError := ValidationError{File: document.Path, Rule: "yaml", Message: message}
+		if matches := unmarshalErrorLine.FindStringSubmatch(message); matches != nil {
+			validationError.Line, _ = strconv.Atoi(matches[1])
+			validationError.Column = 1
+			validationError.File = document.getFileOfLine(validationError.Line)
+			validationError.Message = matches[2]
+		}
+		result = append(result, validationError)
+	}
+	return result
+}
+
+// getValidationErrors converts the errors of ValidateConfigs into ValidationErrors located in the YAML document
+func getValidationErrors(document *configDocument, config interface{}, err error) ValidationErrors {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return ValidationErrors{{File: document.Path, Message: err.Error()}}
+	}
+
+	var result ValidationErrors
+	for _, fieldError := range fieldErrors {
+		keyPath := getYamlKeyPath(reflect.TypeOf(config), fieldError.Namespace())
+		node := findYamlNode(document.Root, keyPath)
+		result = append(result, ValidationError{
+			File:    document.getFile(node),
+			Line:    node.Line,
+			Column:  node.Column,
+			Path:    formatYamlKeyPath(keyPath),
+			Rule:    fieldError.Tag(),
+			Message: getValidationMessage(fieldError),
+		})
+	}
+	return result
+}
+
+// yamlKey is one element of a YAML key path, either a mapping key or a sequence index
+type yamlKey struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// getYamlKeyPath maps a validator namespace like "NodeGroupConfig.LaunchTemplate.BlockDeviceMappings[0].Throughput"
+// to the YAML keys of the fields, following the yaml tags of configType. Namespace is used rather than StructNamespace
+// because it keeps the full field name passed to ReportError by the struct level validations
+func getYamlKeyPath(configType reflect.Type, namespace string) []yamlKey {
+	var keyPath []yamlKey
+	currentType := configType
+	// the first element is the name of the root struct
+	for _, element := range splitNamespace(namespace)[1:] {
+		name := element
+		var indexes []string
+		if bracket := strings.Index(element, "["); bracket != -1 {
+			name = element[:bracket]
+			indexes = strings.Split(strings.TrimSuffix(element[bracket+1:], "]"), "][")
+		}
+
+		for currentType != nil && currentType.Kind() == reflect.Ptr {
+			currentType = currentType.Elem()
+		}
+		key := strings.ToLower(name[:1]) + name[1:]
+		if currentType != nil && currentType.Kind() == reflect.Struct {
+			if field, ok := currentType.FieldByName(name); ok {
+				if tag := strings.Split(field.Tag.Get("yaml"), ",")[0]; tag != "" {
+					key = tag
+				}
+				currentType = field.Type
+			} else {
+				currentType = nil
+			}
+		} else {
+			currentType = nil
+		}
+		keyPath = append(keyPath, yamlKey{Key: key})
+
+		for _, index := range indexes {
+			for currentType != nil && currentType.Kind() == reflect.Ptr {
+				currentType = currentType.Elem()
+			}
+			if currentType != nil && currentType.Kind() == reflect.Map {
+				keyPath = append(keyPath, yamlKey{Key: index})
+			} else {
+				i, _ := strconv.Atoi(index)
+				keyPath = append(keyPath, yamlKey{Index: i, IsIndex: true})
+			}
+			if currentType != nil && (currentType.Kind() == reflect.Map || currentType.Kind() == reflect.Slice) {
+				currentType = currentType.Elem()
+			}
+		}
+	}
+	return keyPath
+}
+
+// splitNamespace splits a validator namespace on the dots outside of map keys like "Tags[app.kubernetes.io/name]"
+func splitNamespace(namespace string) []string {
+	var elements []string
+	depth := 0
+	start := 0
+	for i, char := range namespace {
+		switch char {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				elements = append(elements, namespace[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(elements, namespace[start:])
+}
+
+func formatYamlKeyPath(keyPath []yamlKey) string {
+	var builder strings.Builder
+	for i, key := range keyPath {
+		if key.IsIndex {
+			builder.WriteString(fmt.Sprintf("[%d]", key.Index))
+			continue
+		}
+		if i > 0 {
+			builder.WriteString(".")
+		}
+		builder.WriteString(key.Key)
+	}
+	return builder.String()
+}
+
+// findYamlNode returns the deepest node of keyPath present in the document, the key rather than the value
+// of mapping entries, so that a missing key points at its parent
+func findYamlNode(root *yamlv3.Node, keyPath []yamlKey) *yamlv3.Node {
+	node, found := root, root
+	for _, key := range keyPath {
+		var next *yamlv3.Node
+		switch {
+		case key.IsIndex && node.Kind == yamlv3.SequenceNode && key.Index < len(node.Content):
+			next = node.Content[key.Index]
+			found = next
+		case !key.IsIndex && node.Kind == yamlv3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key.Key {
+					next = node.Content[i+1]
+					found = node.Content[i]
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return found
+}
+
+// getValidationMessage describes a failed validation tag in words
+func getValidationMessage(fieldError validator.FieldError) string {
+	param := fieldError.Param()
+	isCollection := fieldError.Kind() == reflect.Slice || fieldError.Kind() == reflect.Map
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", lowerFirst(param))
+	case "required_if":
+		return fmt.Sprintf("is required when %s", formatFieldCondition(param))
+	case "excluded_with":
+		return fmt.Sprintf("must not be set together with %s", lowerFirst(param))
+	case "excluded_without":
+		return fmt.Sprintf("must not be set without %s", lowerFirst(param))
+	case "excluded_if":
+		return fmt.Sprintf("must not be set when %s", formatFieldCondition(param))
+	case "min":
+		if isCollection {
+			return fmt.Sprintf("must contain at least %s item(s)", param)
+		}
+		if fieldError.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", param)
+		}
+		return fmt.Sprintf("must be at least %s, got %v", param, fieldError.Value())
+	case "max":
+		if isCollection {
+			return fmt.Sprintf("must contain at most %s item(s)", param)
+		}
+		if fieldError.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", param)
+		}
+		return fmt.Sprintf("must be at most %s, got %v", param, fieldError.Value())
+	case "minfield":
+		return fmt.Sprintf("must be greater than or equal to %s, got %v", lowerFirst(param), fieldError.Value())
+	case "maxfield":
+		return fmt.Sprintf("must be less than or equal to %s, got %v", lowerFirst(param), fieldError.Value())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(strings.Fields(param), ", "), fmt.Sprint(fieldError.Value()))
+	case "unique":
+		if param != "" {
+			return fmt.Sprintf("must not contain two items with the same %s", lowerFirst(param))
+		}
+		return "must not contain duplicates"
+	case "cidrv4":
+		return fmt.Sprintf("must be an IPv4 CIDR like 10.0.0.0/16, got %q", fieldError.Value())
+	case "json":
+		return "must be a valid JSON document"
+	case "clustername":
+		return fmt.Sprintf("must start with a letter or digit and contain only letters, digits, hyphens and underscores, got %q", fieldError.Value())
+	case "kubernetesversion":
+		versions := GetKubernetesVersions()
+		return fmt.Sprintf("must be one of the EKS versions %s to %s listed in eks-versions.json, got %q", versions[0].Version, versions[len(versions)-1].Version, fieldError.Value())
+	case "releaseversion":
+		return fmt.Sprintf("must be an EKS AMI release version like 1.29.3-20240514, got %q", fieldError.Value())
+	case "releaseofversion":
+		return fmt.Sprintf("must be a release of version %s, got %q", param, fieldError.Value())
+	case "excluded_with_customami":
+		return "must not be set when amiType is CUSTOM, the version comes from the AMI"
+	case "subnetid":
+		return fmt.Sprintf("must be a subnet ID like subnet-0123456789abcdef0, got %q", fieldError.Value())
+	case "securitygroupid":
+		return fmt.Sprintf("must be a security group ID like sg-0123456789abcdef0, got %q", fieldError.Value())
+	case "instancetype":
+		return fmt.Sprintf("must be an EC2 instance type like m5.large, got %q", fieldError.Value())
+	case "rolearn":
+		return fmt.Sprintf("must be an IAM role ARN like arn:aws:iam::123456789012:role/name, got %q", fieldError.Value())
+	case "policyarn":
+		return fmt.Sprintf("must be an IAM policy ARN like arn:aws:iam::aws:policy/name, got %q", fieldError.Value())
+	case "principalarn":
+		return fmt.Sprintf("must be an IAM role or user ARN, got %q", fieldError.Value())
+	case "kmskeyarn":
+		return fmt.Sprintf("must be a KMS key ARN like arn:aws:kms:ap-south-1:123456789012:key/<key id>, got %q", fieldError.Value())
+	case "amiid":
+		return fmt.Sprintf("must be an AMI ID like ami-0123456789abcdef0, got %q", fieldError.Value())
+	case "thumbprint":
+		return "must be a 40 character hex encoded SHA-1 thumbprint"
+	case "tainteffect":
+		return fmt.Sprintf("must be one of NO_SCHEDULE, NO_EXECUTE, PREFER_NO_SCHEDULE, got %q", fieldError.Value())
+	case "addonname":
+		return fmt.Sprintf("must be a known EKS add-on, got %q", fieldError.Value())
+	case "accesspolicy":
+		return fmt.Sprintf("must be a known EKS access policy, got %q", fieldError.Value())
+	case "addonversion":
+		return fmt.Sprintf("must be latest-compatible or an add-on version like v1.18.1-eksbuild.1, got %q", fieldError.Value())
+	case "configurationvalues":
+		return "must be a valid JSON or YAML document"
+	case "required_oidc":
+		return "requires the oidc section to create the IRSA role"
+	case "irsarolename":
+		return fmt.Sprintf("is required as the default role name %s is longer than the %d characters IAM allows", param, MaxRoleNameLength)
+	case "addonrolename":
+		return fmt.Sprintf("creates the role %s which is longer than the %d characters IAM allows, set serviceAccountRoleArn to an existing role instead", param, MaxRoleNameLength)
+	case "addonserviceaccount":
+		return fmt.Sprintf("is not supported by the %s add-on, which does not call AWS APIs", param)
+	case "excluded_with_launchtemplate":
+		return "must be set in the launch template instead when a launch template is used"
+	case "amitype":
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(AmiTypes, ", "), fieldError.Value())
+	case "capacitytype":
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(CapacityTypes, ", "), fieldError.Value())
+	case "maxunavailabletype":
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(MaximumUnavailableTypes, ", "), fieldError.Value())
+	case "maxunavailablepercentage":
+		return fmt.Sprintf("must be between 1 and 100 for a percentage, got %v", fieldError.Value())
+	case "maxunavailablenumber":
+		return fmt.Sprintf("must be between 1 and maxSize %s for a number, got %v", param, fieldError.Value())
+	case "required_launchtemplate":
+		return "requires a launch template"
+	case "required_customami":
+		return "is required with amiType CUSTOM, it has to run the bootstrap of the AMI so that the nodes join the cluster"
+	case "customami":
+		return fmt.Sprintf("must be set exactly when amiType is CUSTOM, amiType is %q", param)
+	case "gp3only":
+		return fmt.Sprintf("is only supported by gp3 volumes, not %s", param)
+	case "excluded_with_configmap":
+		return "must be empty while the authentication mode is CONFIG_MAP"
+	case "servicecidrprefix":
+		return fmt.Sprintf("must have a prefix length between /12 and /24, got %q with /%s", fieldError.Value(), param)
+	case "servicecidrprivate":
+		return fmt.Sprintf("must be within one of the private blocks 10.0.0.0/8, 172.16.0.0/12 or 192.168.0.0/16, got %q", fieldError.Value())
+	case "servicecidrvpc":
+		return fmt.Sprintf("must not overlap the VPC CIDR %s, got %q", param, fieldError.Value())
+	case "servicecidrpod":
+		return fmt.Sprintf("must not overlap the pod CIDR %s, got %q", param, fieldError.Value())
+	case "excluded_without_publicaccess":
+		return "must be empty while the public endpoint is disabled"
+	case "required_without_publicaccess":
+		return "must be true while the public endpoint is disabled"
+	case "excluded_without_logtypes":
+		return "must not be set while logging is disabled with enabledLogTypes: []"
+	}
+	if param != "" {
+		return fmt.Sprintf("failed the %s=%s validation", fieldError.Tag(), param)
+	}
+	return fmt.Sprintf("failed the %s validation", fieldError.Tag())
+}
+
+// formatFieldCondition turns a "Field value" parameter of the conditional tags into "field is value"
+func formatFieldCondition(param string) string {
+	fields := strings.Fields(param)
+	if len(fields) != 2 {
+		return param
+	}
+	return fmt.Sprintf("%s is %s", lowerFirst(fields[0]), fields[1])
+}
+
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// ConfigTree is the config tree under a root directory, the valid clusters with their nodegroups and fargate profiles
+type ConfigTree struct {
+	Clusters        map[string]ClusterConfig          // by cluster name
+	NodeGroups      map[string][]NodeGroupConfig      // by cluster name, for the clusters whose nodegroups are valid
+	FargateProfiles map[string][]FargateProfileConfig // by cluster name, for the clusters whose fargate profiles are valid
+}
+
+// TreeOptions are the checks of a config tree that are set by the stack config and the flags of eks-iaac-lint
+type TreeOptions struct {
+	CheckClusterDirNames bool          // require the name of every cluster to match its directory, check-cluster-dir-names
+	SupportPolicy        VersionPolicy // when the end of support of a cluster version is warned about, version-warning-days
+}
+
+// DefaultTreeOptions returns the TreeOptions used when neither the stack config nor the flags set them
+func DefaultTreeOptions() TreeOptions {
+	return TreeOptions{SupportPolicy: VersionPolicy{WarningDays: DefaultVersionWarningDays, Now: time.Now}}
+}
+
+// ReadConfigTree reads every cluster config under rootDir with its nodegroups and fargate profiles, it returns
+// the valid configs along with all problems found, the warnings included
+func ReadConfigTree(rootDir string, options TreeOptions) (ConfigTree, ValidationErrors) {
+	var errs ValidationErrors
+	clusterConfigs, err := ReadClusterConfigs(rootDir, options)
+	errs = appendValidationErrors(errs, rootDir, err)
+	tree := ConfigTree{Clusters: clusterConfigs, NodeGroups: map[string][]NodeGroupConfig{}, FargateProfiles: map[string][]FargateProfileConfig{}}
+
+	// the versions close to the end of their support are warned about, the ones past it are rejected
+	clustersByDir := map[string]ClusterConfig{}
+	for _, name := range GetClusterNames(clusterConfigs) {
+		errs = append(errs, options.SupportPolicy.checkVersionSupport(clusterConfigs[name])...)
+		clustersByDir[clusterConfigs[name].Dir] = clusterConfigs[name]
+	}
+
+	// the nodegroups and fargate profiles of the clusters with an invalid config.yaml are checked too
+	clusterDirs, err := listClusterDirs(rootDir)
+	errs = appendValidationErrors(errs, rootDir, err)
+	for _, clusterDir := range clusterDirs {
+		clusterConfig, clusterOk := clustersByDir[clusterDir]
+
+		nodeGroupConfigs, warnings, err := readNodeConfigs(filepath.Join(clusterDir, "nodegroups"))
+		errs = append(errs, warnings...)
+		errs = appendValidationErrors(errs, filepath.Join(clusterDir, "nodegroups"), err)
+
+		// the nodegroups are checked against their cluster once both are valid on their own
+		if clusterOk && err == nil {
+			err = ValidateClusterNodeGroups(clusterConfig, nodeGroupConfigs)
+			errs = appendValidationErrors(errs, filepath.Join(clusterDir, "nodegroups"), err)
+			if err == nil {
+				tree.NodeGroups[clusterConfig.Name] = nodeGroupConfigs
+			}
+		}
+
+		fargateProfileConfigs, err := ReadFargateProfileConfigs(filepath.Join(clusterDir, "fargateprofiles"))
+		errs = appendValidationErrors(errs, filepath.Join(clusterDir, "fargateprofiles"), err)
+		if clusterOk && err == nil {
+			tree.FargateProfiles[clusterConfig.Name] = fargateProfileConfigs
+		}
+	}
+
+	return tree, removeDuplicateValidationErrors(errs)
+}
+
+// CheckConfigTree reads every cluster config under rootDir with its nodegroups and fargate profiles
+// and returns all problems found, the warnings included
+func CheckConfigTree(rootDir string, options TreeOptions) ValidationErrors {
+	_, errs := ReadConfigTree(rootDir, options)
+	return errs
+}
+
+// ValidateConfigTree reads the config tree under rootDir, logs its warnings and returns it with its errors
+// as ValidationErrors, nil when the whole tree is valid
+func ValidateConfigTree(rootDir string, options TreeOptions) (ConfigTree, error) {
+	log.Printf("Validating the config tree: %s", rootDir)
+
+	tree, validationErrors := ReadConfigTree(rootDir, options)
+	errs, warnings := validationErrors.Split()
+	for _, warning := range warnings {
+		log.Println(warning.Error())
+	}
+	if len(errs) > 0 {
+		return tree, errs
+	}
+
+	log.Printf("Successfully validated the config tree: %s", rootDir)
+
+	return tree, nil
+}
+
+// removeDuplicateValidationErrors keeps the first of identical errors, the environment defaults
+// are read again for the nodegroups of every cluster
+func removeDuplicateValidationErrors(errs ValidationErrors) ValidationErrors {
+	seen := map[ValidationError]bool{}
+	var result ValidationErrors
+	for _, validationError := range errs {
+		if !seen[validationError] {
+			seen[validationError] = true
+			result = append(result, validationError)
+		}
+	}
+	return result
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// eksVersionsJSON lists the Kubernetes versions of EKS with their end of support dates, from the EKS
+// Kubernetes version calendar, a version is added to it when EKS releases it
+//
+//go:embed eks-versions.json
+var eksVersionsJSON []byte
+
+// KubernetesVersion is a Kubernetes version of EKS and the dates its standard and extended support end
+type KubernetesVersion struct {
+	Version              string `json:"version"`              // major.minor like 1.29
+	EndOfStandardSupport string `json:"endOfStandardSupport"` // YYYY-MM-DD
+	EndOfExtendedSupport string `json:"endOfExtendedSupport"` // YYYY-MM-DD, EKS upgrades the remaining clusters after it
+}
+
+var kubernetesVersions = mustParseKubernetesVersions(eksVersionsJSON)
+
+func mustParseKubernetesVersions(data []byte) []KubernetesVersion {
+	var list struct {
+		Versions []KubernetesVersion `json:"versions"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		panic("invalid embedded EKS version list: " + err.Error())
+	}
+	for _, version := range list.Versions {
+		_, err1 := time.Parse(time.DateOnly, version.EndOfStandardSupport)
+		_, err2 := time.Parse(time.DateOnly, version.EndOfExtendedSupport)
+		if _, ok := parseMinorVersion(version.Version); !ok || err1 != nil || err2 != nil {
+			panic(fmt.Sprintf("invalid embedded EKS version %q", version.Version))
+		}
+	}
+	return list.Versions
+}
+
+// GetKubernetesVersions returns the Kubernetes versions of EKS, the oldest first
+func GetKubernetesVersions() []KubernetesVersion {
+	return kubernetesVersions
+}
+
+// LookupKubernetesVersion returns the listed Kubernetes version, and whether it is listed
+func LookupKubernetesVersion(version string) (KubernetesVersion, bool) {
+	for _, kubernetesVersion := range kubernetesVersions {
+		if kubernetesVersion.Version == version {
+			return kubernetesVersion, true
+		}
+	}
+	return KubernetesVersion{}, false
+}
+
+// parseMinorVersion returns the minor version of a 1.x Kubernetes version
+func parseMinorVersion(version string) (int, bool) {
+	minor, ok := strings.CutPrefix(version, "1.")
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.Atoi(minor)
+	return number, err == nil && number >= 0 && strconv.Itoa(number) == minor
+}
+
+// isSupportedKubernetesVersion reports whether version is listed, a version EKS released since the list was
+// updated is rejected until it is added to eks-versions.json along with its end of support dates
+func isSupportedKubernetesVersion(version string) bool {
+	_, ok := LookupKubernetesVersion(version)
+	return ok
+}
+
+// isReleaseOfVersion reports whether an AMI release version like 1.29.3-20240514 belongs to the version 1.29
+func isReleaseOfVersion(releaseVersion, version string) bool {
+	return strings.HasPrefix(releaseVersion, version+".")
+}
+
+// maxNodeVersionSkew is how many minor versions the nodes may be older than the control plane
+const maxNodeVersionSkew = 3
+
+// VersionPolicy decides when the end of support of a cluster version is warned about
+type VersionPolicy struct {
+	WarningDays int              // days before the end of standard support a version is warned about
+	Now         func() time.Time // the clock the end of support dates are compared to
+}
+
+// DefaultVersionWarningDays is the WarningDays of the VersionPolicy of DefaultTreeOptions
+const DefaultVersionWarningDays = 90
+
+// checkVersionSupport warns about a cluster version close to or past the end of its standard support, and
+// rejects a version past the end of its extended support as EKS no longer runs it
+func (p VersionPolicy) checkVersionSupport(clusterConfig ClusterConfig) ValidationErrors {
+	message, warning := p.getVersionSupportMessage(clusterConfig.Version)
+	if message == "" {
+		return nil
+	}
+	validationError := newVersionError(clusterConfig, "version_support", message)
+	validationError.Warning = warning
+	return ValidationErrors{validationError}
+}
+
+// getVersionSupportMessage returns the support problem of version, if any, and whether it is only a warning
+func (p VersionPolicy) getVersionSupportMessage(version string) (string, bool) {
+	kubernetesVersion, ok := LookupKubernetesVersion(version)
+	if !ok {
+		return "", false
+	}
+
+	now := p.Now()
+	endOfStandardSupport, _ := time.Parse(time.DateOnly, kubernetesVersion.EndOfStandardSupport)
+	endOfExtendedSupport, _ := time.Parse(time.DateOnly, kubernetesVersion.EndOfExtendedSupport)
+	switch {
+	case !now.Before(endOfExtendedSupport):
+		return fmt.Sprintf("version %s reached the end of extended support on %s, EKS no longer supports it, upgrade to %s or newer",
+			version, kubernetesVersion.EndOfExtendedSupport, p.getOldestSupportedVersion()), false
+	case !now.Before(endOfStandardSupport):
+		return fmt.Sprintf("version %s reached the end of standard support on %s, the cluster is billed for extended support until %s",
+			version, kubernetesVersion.EndOfStandardSupport, kubernetesVersion.EndOfExtendedSupport), true
+	}
+	days := int(math.Ceil(endOfStandardSupport.Sub(now).Hours() / 24))
+	if days <= p.WarningDays {
+		return fmt.Sprintf("version %s reaches the end of standard support on %s, in %d day(s)", version, kubernetesVersion.EndOfStandardSupport, days), true
+	}
+	return "", false
+}
+
+// getOldestSupportedVersion returns the oldest listed version still in extended support, the newest listed
+// version once all of them reached its end
+func (p VersionPolicy) getOldestSupportedVersion() string {
+	now := p.Now()
+	for _, kubernetesVersion := range kubernetesVersions {
+		endOfExtendedSupport, _ := time.Parse(time.DateOnly, kubernetesVersion.EndOfExtendedSupport)
+		if now.Before(endOfExtendedSupport) {
+			return kubernetesVersion.Version
+		}
+	}
+	return kubernetesVersions[len(kubernetesVersions)-1].Version
+}
+
+// CheckVersionUpgrades checks the cluster versions against the versions they are deployed with, by cluster
+// name, as EKS upgrades the control plane one minor version at a time and never downgrades it. An upgraded
+// cluster needs a version or latest-compatible on its add-ons, EKS keeps the installed version of the others.
+// The clusters without a deployed version are new.
+func CheckVersionUpgrades(clusterConfigs map[string]ClusterConfig, deployedVersions map[string]string) error {
+	var errs ValidationErrors
+	for _, name := range GetClusterNames(clusterConfigs) {
+		clusterConfig := clusterConfigs[name]
+		deployedVersion := deployedVersions[name]
+		deployedMinor, ok := parseMinorVersion(deployedVersion)
+		if !ok {
+			continue
+		}
+		minor, ok := parseMinorVersion(clusterConfig.Version)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case minor < deployedMinor:
+			errs = append(errs, newVersionError(clusterConfig, "version_downgrade",
+				fmt.Sprintf("version %s is older than the deployed version %s, EKS can not downgrade a cluster", clusterConfig.Version, deployedVersion)))
+		case minor > deployedMinor+1:
+			errs = append(errs, newVersionError(clusterConfig, "version_upgrade",
+				fmt.Sprintf("version %s is more than one minor version above the deployed version %s, upgrade to 1.%d first", clusterConfig.Version, deployedVersion, deployedMinor+1)))
+		case minor > deployedMinor:
+			for i, addonConfig := range clusterConfig.Addons {
+				if addonConfig.Version != "" {
+					continue
+				}
+				errs = append(errs, newAddonVersionError(clusterConfig, i, "addon_upgrade",
+					fmt.Sprintf("add-on %s keeps its installed version when the cluster is upgraded from %s to %s, pin a version of it for %s",
+						addonConfig.Name, deployedVersion, clusterConfig.Version, clusterConfig.Version)))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ReadClusterVersions returns the versions of the clusters under rootDir by cluster name, reading only the
+// name and version of every config.yaml so that a config tree of an older revision can be compared
+func ReadClusterVersions(rootDir string) (map[string]string, error) {
+	clusterDirs, err := listClusterDirs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, clusterDir := range clusterDirs {
+		data, err := os.ReadFile(filepath.Join(clusterDir, clusterConfigFile))
+		if err != nil {
+			return nil, err
+		}
+		var clusterConfig struct {
+			Name    string `yaml:"name"`
+			Version string `yaml:"version"`
+		}
+		if err := yamlv3.Unmarshal(data, &clusterConfig); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(clusterDir, clusterConfigFile), err)
+		}
+		if clusterConfig.Name != "" {
+			versions[clusterConfig.Name] = clusterConfig.Version
+		}
+	}
+	return versions, nil
+}
+
+// newVersionError returns a problem of the version of the cluster, located in its config.yaml when it was read from one
+func newVersionError(clusterConfig ClusterConfig, rule, message string) ValidationError {
+	validationError := ValidationError{Path: "version", Rule: rule, Message: message}
+	if document := clusterConfig.document; document != nil {
+		node := findYamlNode(document.Root, []yamlKey{{Key: "version"}})
+		validationError.File, validationError.Line, validationError.Column = document.getFile(node), node.Line, node.Column
+	}
+	return validationError
+}
+
+// newAddonVersionError returns a problem of the version of the add-on at index of the cluster, located on the
+// add-on itself when it has no version
+func newAddonVersionError(clusterConfig ClusterConfig, index int, rule, message string) ValidationError {
+	keyPath := []yamlKey{{Key: "addons"}, {Index: index, IsIndex: true}, {Key: "version"}}
+	validationError := ValidationError{Path: formatYamlKeyPath(keyPath), Rule: rule, Message: message}
+	if document := clusterConfig.document; document != nil {
+		node := findYamlNode(document.Root, keyPath)
+		validationError.File, validationError.Line, validationError.Column = document.getFile(node), node.Line, node.Column
+	}
+	return validationError
+}